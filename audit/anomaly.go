@@ -0,0 +1,202 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"github.com/zerotohero-dev/aegis-core/env"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DetectorConfig configures a Detector.
+type DetectorConfig struct {
+	// Length of the sliding window.
+	Window time.Duration
+	// Number of watched events from a single SVID, within Window, that
+	// raises an anomaly.
+	Threshold int
+	// The events to watch.
+	Events []Event
+	// How long an SVID stays denied after raising an anomaly.
+	DenyFor time.Duration
+	// Optional hook that is called, in its own goroutine, for every anomaly.
+	// Aegis Safe can use it to reject the requests of the offending SVID
+	// until the given time.
+	Deny func(svid string, until time.Time)
+}
+
+// DetectorConfigFromEnv returns the detector configuration that the
+// environment specifies; see env.AuditAnomalyWindow,
+// env.AuditAnomalyThreshold, and env.AuditAnomalyDenyDuration. The detector
+// watches EventBadSvid, EventBadPeerSvid, and EventNoSecret.
+func DetectorConfigFromEnv() DetectorConfig {
+	return DetectorConfig{
+		Window:    env.AuditAnomalyWindow(),
+		Threshold: env.AuditAnomalyThreshold(),
+		Events:    []Event{EventBadSvid, EventBadPeerSvid, EventNoSecret},
+		DenyFor:   env.AuditAnomalyDenyDuration(),
+	}
+}
+
+// Anomaly is the entity of the EventAnomaly entries that a Detector logs.
+type Anomaly struct {
+	// The offending SVID.
+	Svid string
+	// Number of watched events within the window.
+	Count  int
+	Window time.Duration
+	// The SVID is denied until this time.
+	DeniedUntil time.Time
+}
+
+func (a Anomaly) AuditFields() map[string]string {
+	return map[string]string{
+		"count":       strconv.Itoa(a.Count),
+		"window":      a.Window.String(),
+		"deniedUntil": a.DeniedUntil.UTC().Format(time.RFC3339),
+	}
+}
+
+// Detector is a Sink that watches the audit stream for bursts of
+// authorization failures from a single SVID. When the number of watched
+// events from an SVID within the sliding window reaches the threshold, the
+// detector logs an EventAnomaly entry, denies the SVID for a while (see
+// Denied), and calls the Deny hook, if there is one.
+type Detector struct {
+	mux       sync.Mutex
+	config    DetectorConfig
+	watched   map[Event]bool
+	hits      map[string][]time.Time
+	denied    map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewDetector creates a Detector. Register it as a Sink to feed it the audit
+// stream.
+func NewDetector(c DetectorConfig) *Detector {
+	if c.Threshold < 1 {
+		c.Threshold = 1
+	}
+	watched := map[Event]bool{}
+	for _, e := range c.Events {
+		watched[e] = true
+	}
+	return &Detector{
+		config:  c,
+		watched: watched,
+		hits:    map[string][]time.Time{},
+		denied:  map[string]time.Time{},
+	}
+}
+
+func (d *Detector) Write(e JournalEntry) error {
+	if !d.watched[e.Event] || e.Svid == "" {
+		return nil
+	}
+
+	now := e.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	d.mux.Lock()
+	d.sweep(now)
+	// The rest of the burst of a denied SVID raises no further anomalies.
+	if until, ok := d.denied[e.Svid]; ok && now.Before(until) {
+		d.mux.Unlock()
+		return nil
+	}
+	hits := append(prune(d.hits[e.Svid], now.Add(-d.config.Window)), now)
+	if len(hits) < d.config.Threshold {
+		d.hits[e.Svid] = hits
+		d.mux.Unlock()
+		return nil
+	}
+
+	// Start counting afresh once the SVID is no longer denied.
+	delete(d.hits, e.Svid)
+	until := now.Add(d.config.DenyFor)
+	d.denied[e.Svid] = until
+	d.mux.Unlock()
+
+	a := Anomaly{
+		Svid:        e.Svid,
+		Count:       len(hits),
+		Window:      d.config.Window,
+		DeniedUntil: until,
+	}
+
+	// Log, and the hook, run in their own goroutines: Write is called while
+	// the sinks are being dispatched to, so logging synchronously here would
+	// re-enter the dispatcher.
+	go Log(JournalEntry{
+		CorrelationId: e.CorrelationId,
+		Entity:        a,
+		Method:        e.Method,
+		Url:           e.Url,
+		Svid:          e.Svid,
+		Event:         EventAnomaly,
+	})
+	if d.config.Deny != nil {
+		go d.config.Deny(e.Svid, until)
+	}
+
+	return nil
+}
+
+func (d *Detector) Close() error {
+	return nil
+}
+
+// Denied returns true if svid has raised an anomaly, and its deny period
+// has not ended yet.
+func (d *Detector) Denied(svid string) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	until, ok := d.denied[svid]
+	return ok && time.Now().Before(until)
+}
+
+// sweep drops the state of the SVIDs that have been quiet for a whole window,
+// so that the detector does not grow unbounded. Must be called with d.mux
+// held.
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.config.Window {
+		return
+	}
+	d.lastSweep = now
+
+	cutoff := now.Add(-d.config.Window)
+	for svid, hits := range d.hits {
+		if hits = prune(hits, cutoff); len(hits) == 0 {
+			delete(d.hits, svid)
+		} else {
+			d.hits[svid] = hits
+		}
+	}
+	for svid, until := range d.denied {
+		if !now.Before(until) {
+			delete(d.denied, svid)
+		}
+	}
+}
+
+// prune drops the hits that are not after cutoff. The hits are not
+// necessarily in time order, since the workers of the pipeline can deliver
+// entries out of order.
+func prune(hits []time.Time, cutoff time.Time) []time.Time {
+	kept := hits[:0]
+	for _, h := range hits {
+		if h.After(cutoff) {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,220 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"testing"
+	"time"
+)
+
+const badSvid = "spiffe://aegis.ist/workload/bad"
+
+func newDetector(c audit.DetectorConfig) *audit.Detector {
+	if c.Window == 0 {
+		c.Window = time.Minute
+	}
+	if c.DenyFor == 0 {
+		c.DenyFor = time.Hour
+	}
+	c.Events = []audit.Event{audit.EventBadSvid, audit.EventNoSecret}
+	return audit.NewDetector(c)
+}
+
+// hit feeds d a watched event from svid at the given time.
+func hit(t *testing.T, d *audit.Detector, svid string, at time.Time) {
+	t.Helper()
+	if err := d.Write(audit.JournalEntry{
+		Svid: svid, Event: audit.EventBadSvid, Time: at,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// anomalies waits for the detector to log want anomalies, and returns them.
+func anomalies(t *testing.T, sink *audit.MemorySink, want int) []audit.Anomaly {
+	t.Helper()
+	var found []audit.Anomaly
+	for deadline := time.Now().Add(time.Second); ; {
+		found = nil
+		for _, e := range sink.Entries() {
+			if e.Event == audit.EventAnomaly {
+				found = append(found, e.Entity.(audit.Anomaly))
+			}
+		}
+		if len(found) >= want || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Give stray anomalies a chance to show up.
+	time.Sleep(10 * time.Millisecond)
+	if n := len(sink.Entries()); n != len(found) {
+		t.Errorf("got %d entries, want %d anomalies", n, want)
+	}
+	if len(found) != want {
+		t.Fatalf("got %d anomalies, want %d", len(found), want)
+	}
+	return found
+}
+
+func TestDetectorThreshold(t *testing.T) {
+	sink := audit.NewMemorySink()
+	withSink(t, "anomaly-test-threshold", sink)
+	d := newDetector(audit.DetectorConfig{Threshold: 3})
+	now := time.Now()
+
+	hit(t, d, badSvid, now)
+	hit(t, d, badSvid, now.Add(time.Second))
+	// Unwatched events, and events of other SVIDs, do not count.
+	_ = d.Write(audit.JournalEntry{Svid: badSvid, Event: audit.EventOk, Time: now})
+	_ = d.Write(audit.JournalEntry{Event: audit.EventBadSvid, Time: now})
+	hit(t, d, "spiffe://aegis.ist/workload/good", now)
+	if d.Denied(badSvid) {
+		t.Fatal("want no anomaly below the threshold")
+	}
+
+	hit(t, d, badSvid, now.Add(2*time.Second))
+	if !d.Denied(badSvid) {
+		t.Error("want the SVID to be denied at the threshold")
+	}
+	a := anomalies(t, sink, 1)[0]
+	if a.Svid != badSvid || a.Count != 3 || a.Window != time.Minute ||
+		!a.DeniedUntil.Equal(now.Add(2*time.Second+time.Hour)) {
+		t.Errorf("got %+v", a)
+	}
+	if d.Denied("spiffe://aegis.ist/workload/good") {
+		t.Error("want other SVIDs not to be denied")
+	}
+}
+
+func TestDetectorSlidingWindow(t *testing.T) {
+	sink := audit.NewMemorySink()
+	withSink(t, "anomaly-test-window", sink)
+	d := newDetector(audit.DetectorConfig{Threshold: 3})
+	now := time.Now()
+
+	hit(t, d, badSvid, now)
+	hit(t, d, badSvid, now.Add(40*time.Second))
+	// The first hit has left the window.
+	hit(t, d, badSvid, now.Add(70*time.Second))
+	if d.Denied(badSvid) {
+		t.Fatal("want hits outside the window not to count")
+	}
+
+	hit(t, d, badSvid, now.Add(90*time.Second))
+	if !d.Denied(badSvid) {
+		t.Error("want three hits within the window to raise an anomaly")
+	}
+	anomalies(t, sink, 1)
+}
+
+func TestDetectorOutOfOrderHits(t *testing.T) {
+	sink := audit.NewMemorySink()
+	withSink(t, "anomaly-test-order", sink)
+	d := newDetector(audit.DetectorConfig{Threshold: 3})
+	now := time.Now()
+
+	// With several pipeline workers, an older entry can arrive late.
+	hit(t, d, badSvid, now.Add(50*time.Second))
+	hit(t, d, badSvid, now)
+	// The hit at now has left the window, even though it is not the
+	// first hit that the detector has seen.
+	hit(t, d, badSvid, now.Add(70*time.Second))
+	if d.Denied(badSvid) {
+		t.Fatal("want hits outside the window not to count")
+	}
+
+	hit(t, d, badSvid, now.Add(80*time.Second))
+	if !d.Denied(badSvid) {
+		t.Error("want three hits within the window to raise an anomaly")
+	}
+	anomalies(t, sink, 1)
+}
+
+func TestDetectorRaisesOneAnomalyPerBurst(t *testing.T) {
+	sink := audit.NewMemorySink()
+	withSink(t, "anomaly-test-burst", sink)
+
+	denies := make(chan time.Time, 10)
+	d := newDetector(audit.DetectorConfig{
+		Threshold: 2,
+		DenyFor:   30 * time.Second,
+		Deny: func(svid string, until time.Time) {
+			if svid == badSvid {
+				denies <- until
+			}
+		},
+	})
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		hit(t, d, badSvid, now.Add(time.Duration(i)*time.Second))
+	}
+	if a := anomalies(t, sink, 1)[0]; a.Count != 2 {
+		t.Errorf("got %+v", a)
+	}
+	select {
+	case until := <-denies:
+		if !until.Equal(now.Add(31 * time.Second)) {
+			t.Errorf("got the SVID denied until %v", until)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want the Deny hook to be called")
+	}
+	if len(denies) != 0 {
+		t.Errorf("want the Deny hook to be called once, got %d more", len(denies))
+	}
+
+	// Once the deny period is over, a new burst raises a new anomaly.
+	sink.Reset()
+	hit(t, d, badSvid, now.Add(40*time.Second))
+	hit(t, d, badSvid, now.Add(41*time.Second))
+	anomalies(t, sink, 1)
+}
+
+func TestDetectorDeniedExpires(t *testing.T) {
+	sink := audit.NewMemorySink()
+	withSink(t, "anomaly-test-expiry", sink)
+	d := newDetector(audit.DetectorConfig{
+		Threshold: 1, DenyFor: 50 * time.Millisecond,
+	})
+
+	hit(t, d, badSvid, time.Now())
+	if !d.Denied(badSvid) {
+		t.Fatal("want the SVID to be denied")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if d.Denied(badSvid) {
+		t.Error("want the deny period to end")
+	}
+	anomalies(t, sink, 1)
+}
+
+func TestDetectorSweepsQuietSvids(t *testing.T) {
+	sink := audit.NewMemorySink()
+	withSink(t, "anomaly-test-sweep", sink)
+	d := newDetector(audit.DetectorConfig{Threshold: 2, DenyFor: time.Minute})
+	now := time.Now()
+
+	hit(t, d, "spiffe://aegis.ist/workload/a", now)
+	hit(t, d, "spiffe://aegis.ist/workload/b", now)
+	hit(t, d, badSvid, now)
+	hit(t, d, badSvid, now)
+	if hits, denied := audit.TrackedSvids(d); hits != 2 || denied != 1 {
+		t.Fatalf("got %d SVIDs with hits, %d denied", hits, denied)
+	}
+	anomalies(t, sink, 1)
+
+	// A whole window, and the deny period, later, only the new hit is kept.
+	hit(t, d, "spiffe://aegis.ist/workload/c", now.Add(2*time.Minute))
+	if hits, denied := audit.TrackedSvids(d); hits != 1 || denied != 0 {
+		t.Errorf("got %d SVIDs with hits, %d denied", hits, denied)
+	}
+}
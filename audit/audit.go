@@ -9,8 +9,8 @@
 package audit
 
 import (
-	reqres "github.com/zerotohero-dev/aegis-core/entity/reqres/safe/v1"
-	"github.com/zerotohero-dev/aegis-core/log"
+	"github.com/zerotohero-dev/aegis-core/redact"
+	"time"
 )
 
 type Event string
@@ -23,6 +23,8 @@ const EventBadPeerSvid Event = "aegis-bad-peer-svid"
 const EventNoSecret Event = "aegis-no-secret"
 const EventOk Event = "aegis-ok"
 const EventNoWorkloadId Event = "aegis-no-workload-id"
+const EventExit Event = "aegis-exit"
+const EventAnomaly Event = "aegis-anomaly"
 
 type JournalEntry struct {
 	CorrelationId string
@@ -31,68 +33,30 @@ type JournalEntry struct {
 	Url           string
 	Svid          string
 	Event         Event
+	// HTTP status of the response, if the request has been served.
+	Status int
+	// Time the entry was logged. Log sets it to the current time, if it
+	// is not already set.
+	Time time.Time
 }
 
-func printAudit(correlationId, method, url, svid, message string) {
-	log.InfoLn(
-		correlationId,
-		"method", method,
-		"url", url,
-		"svid", svid,
-		"msg", message,
-	)
-}
-
+// Log delivers the journal entry to every registered Sink.
+//
+// The secret fields of the entity of the entry are redacted before the entry
+// reaches any sink; see redact.Redact.
+//
+// If the asynchronous pipeline is running (see StartPipeline), Log queues
+// the entry and returns without waiting for its delivery.
+//
+// Unlike the functions in the `log` package, Log does not depend on the
+// current log level: audit records are always emitted.
 func Log(e JournalEntry) {
-	if e.Entity == nil {
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid, string(e.Event),
-		)
+	if e.Time.IsZero() {
+		e.Time = time.Now()
 	}
-
-	switch v := e.Entity.(type) {
-	case reqres.SecretFetchRequest:
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid,
-			"e:"+v.Err+"m"+string(e.Event),
-		)
-	case reqres.SecretFetchResponse:
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid,
-			"e:"+v.Err+"c:"+v.Created+"u:"+v.Updated+"m:"+string(e.Event),
-		)
-	case reqres.SecretUpsertRequest:
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid,
-			"e:"+v.Err+"m"+string(e.Event),
-		)
-	case reqres.SecretUpsertResponse:
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid,
-			"e:"+v.Err+"m"+string(e.Event),
-		)
-	case reqres.SecretListRequest:
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid,
-			"e:"+v.Err+"m"+string(e.Event),
-		)
-	case reqres.SecretListResponse:
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid,
-			"e:"+v.Err+"m"+string(e.Event),
-		)
-	default:
-		printAudit(
-			e.CorrelationId,
-			e.Method, e.Url, e.Svid,
-			"e: UNKNOWN ENTITY in AUDIT LOG",
-		)
+	e.Entity = redact.Redact(e.Entity)
+	if enqueue(e) {
+		return
 	}
+	dispatch(e)
 }
@@ -0,0 +1,32 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+
+// Auditable is implemented by the entities that can be recorded in the
+// audit journal. The request and response types in `entity/reqres`
+// implement it, so any new request type only needs an AuditFields method to
+// produce a correct audit record.
+type Auditable interface {
+	// AuditFields returns the fields of the entity to include in its audit
+	// Record. The values of the FieldErr, FieldCreated, and FieldUpdated
+	// keys populate the respective Record fields; the rest of the keys are
+	// recorded in Record.Fields.
+	//
+	// AuditFields must never return secret material.
+	AuditFields() map[string]string
+}
+
+const FieldErr = data.AuditFieldErr
+const FieldCreated = data.AuditFieldCreated
+const FieldUpdated = data.AuditFieldUpdated
+
+// FieldEntity holds the type of an entity that does not implement Auditable.
+const FieldEntity = "entity"
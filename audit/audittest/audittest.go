@@ -0,0 +1,119 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audittest
+
+import (
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// Recorder is an audit.Sink that captures the journal entries, so that tests
+// can assert on them. The entries are captured after redaction, exactly as
+// any other sink would receive them.
+//
+// Recorder is an audit.MemorySink, with assertion helpers.
+type Recorder struct {
+	*audit.MemorySink
+}
+
+var installed atomic.Uint64
+
+// NewRecorder creates an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{MemorySink: audit.NewMemorySink()}
+}
+
+// Install creates a Recorder, and registers it as an audit.Sink for the
+// duration of the test. The recorder is unregistered when the test ends.
+//
+// If the asynchronous pipeline is running (see audit.StartPipeline), call
+// audit.Flush before asserting on the recorder.
+func Install(t testing.TB) *Recorder {
+	t.Helper()
+	name := "audittest-" + strconv.FormatUint(installed.Add(1), 10)
+	r := NewRecorder()
+	audit.RegisterSink(name, r)
+	t.Cleanup(func() {
+		_ = audit.UnregisterSink(name)
+	})
+	return r
+}
+
+// EntriesFor returns the captured entries of the given correlation id.
+func (r *Recorder) EntriesFor(correlationId string) []audit.JournalEntry {
+	var entries []audit.JournalEntry
+	for _, e := range r.Entries() {
+		if e.CorrelationId == correlationId {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Events returns the events of the captured entries of the given correlation
+// id, in order.
+func (r *Recorder) Events(correlationId string) []audit.Event {
+	var events []audit.Event
+	for _, e := range r.EntriesFor(correlationId) {
+		events = append(events, e.Event)
+	}
+	return events
+}
+
+// ExpectEvents reports a test error unless the entries of the given
+// correlation id have exactly the given events, in the given order.
+func (r *Recorder) ExpectEvents(
+	t testing.TB, correlationId string, events ...audit.Event,
+) {
+	t.Helper()
+	got := r.Events(correlationId)
+	if len(got) != len(events) {
+		t.Errorf(
+			"audit events for %q: got %v, want %v", correlationId, got, events,
+		)
+		return
+	}
+	for i := range got {
+		if got[i] != events[i] {
+			t.Errorf(
+				"audit events for %q: got %v, want %v",
+				correlationId, got, events,
+			)
+			return
+		}
+	}
+}
+
+// ExpectEvent reports a test error unless at least one captured entry has
+// the given event.
+func (r *Recorder) ExpectEvent(t testing.TB, event audit.Event) {
+	t.Helper()
+	for _, e := range r.Entries() {
+		if e.Event == event {
+			return
+		}
+	}
+	t.Errorf("audit event %q was not logged", event)
+}
+
+// ExpectNoEvent reports a test error if any captured entry has the given
+// event.
+func (r *Recorder) ExpectNoEvent(t testing.TB, event audit.Event) {
+	t.Helper()
+	for _, e := range r.Entries() {
+		if e.Event == event {
+			t.Errorf(
+				"audit event %q was logged for %q", event, e.CorrelationId,
+			)
+			return
+		}
+	}
+}
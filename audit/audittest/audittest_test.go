@@ -0,0 +1,61 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audittest
+
+import (
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"testing"
+)
+
+// fakeT records the errors that the assertion helpers report.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	r := Install(t)
+	var _ audit.Sink = r
+
+	audit.Log(audit.JournalEntry{CorrelationId: "a", Event: audit.EventEnter})
+	audit.Log(audit.JournalEntry{CorrelationId: "b", Event: audit.EventEnter})
+	audit.Log(audit.JournalEntry{CorrelationId: "a", Event: audit.EventExit})
+
+	if len(r.Entries()) != 3 || len(r.EntriesFor("a")) != 2 {
+		t.Fatalf("got %+v", r.Entries())
+	}
+
+	f := &fakeT{TB: t}
+	r.ExpectEvents(f, "a", audit.EventEnter, audit.EventExit)
+	r.ExpectEvent(f, audit.EventExit)
+	r.ExpectNoEvent(f, audit.EventBadSvid)
+	if len(f.errors) != 0 {
+		t.Errorf("got errors for matching events: %v", f.errors)
+	}
+
+	r.ExpectEvents(f, "a", audit.EventExit, audit.EventEnter)
+	r.ExpectEvents(f, "b", audit.EventEnter, audit.EventExit)
+	r.ExpectEvent(f, audit.EventBadSvid)
+	r.ExpectNoEvent(f, audit.EventEnter)
+	if len(f.errors) != 4 {
+		t.Errorf("got %d errors, want 4: %v", len(f.errors), f.errors)
+	}
+
+	r.Reset()
+	if len(r.Entries()) != 0 {
+		t.Errorf("got %d entries after Reset", len(r.Entries()))
+	}
+}
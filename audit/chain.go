@@ -0,0 +1,477 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/log"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GenesisHash is the `prev` hash of the first link of a hash-chained journal.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// maxLineSize is the largest journal line that the readers in this package
+// accept.
+const maxLineSize = 1024 * 1024
+
+// ChainEntry is the payload of a single link of a hash-chained journal.
+// Exactly one of Record, Checkpoint, and Recovery is set.
+type ChainEntry struct {
+	// Position of the link in the chain, starting from 1.
+	Seq uint64 `json:"seq"`
+	// Hash of the previous link; GenesisHash for the first link.
+	Prev       string      `json:"prev"`
+	Record     *Record     `json:"record,omitempty"`
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+	Recovery   *Recovery   `json:"recovery,omitempty"`
+}
+
+// Recovery records that the journal ended with an incomplete line when
+// NewChainSink opened it, such as after a crash in the middle of a write.
+// The incomplete line is left in place, and the chain continues from the
+// last complete link, with the Recovery link right after the incomplete
+// line.
+type Recovery struct {
+	Time time.Time `json:"time"`
+	// SHA-256 hash of the incomplete line, so that it cannot be replaced
+	// without breaking the chain.
+	Skipped string `json:"skipped"`
+}
+
+// Checkpoint summarizes the chain up to, and excluding, the checkpoint
+// itself. Copying checkpoints to a separate location (or printing them to
+// a system that the journal writer cannot modify) makes it possible to
+// detect the removal of the tail of the journal too.
+type Checkpoint struct {
+	Time time.Time `json:"time"`
+	// Number of links in the chain before this checkpoint.
+	Count uint64 `json:"count"`
+	// Hash of the last link before this checkpoint.
+	Head string `json:"head"`
+	// Signature of the checkpoint, if the chain is signed. Since Head
+	// transitively covers every link before it, signing the checkpoint
+	// signs the whole batch of records since the previous checkpoint.
+	KeyId     string `json:"keyId,omitempty"`
+	Signature string `json:"sig,omitempty"`
+}
+
+// payload returns the bytes that the signature of the checkpoint covers.
+func (c Checkpoint) payload() []byte {
+	return []byte(fmt.Sprintf(
+		"%s:%d:%s", c.Time.UTC().Format(time.RFC3339Nano), c.Count, c.Head,
+	))
+}
+
+// chainLink is how a ChainEntry is written to the journal. The hash is
+// computed over the exact bytes of the entry, so that verification does not
+// depend on re-serializing it.
+type chainLink struct {
+	Entry json.RawMessage `json:"entry"`
+	Hash  string          `json:"hash"`
+}
+
+// ChainSink appends audit journal entries to a file, where every line is
+// linked to the SHA-256 hash of the line before it. Editing or removing any
+// line, other than the trailing ones, breaks the chain; see VerifyChain.
+type ChainSink struct {
+	mux                sync.Mutex
+	file               *os.File
+	seq                uint64
+	head               string
+	sinceCheckpoint    int
+	checkpointInterval int
+	signer             *Signer
+}
+
+// NewChainSink opens (or creates) the hash-chained journal at path. If the
+// journal already has links, new links continue the existing chain.
+//
+// If the last line of the journal is incomplete, such as after a crash, it
+// is reported, and the chain continues after it with a Recovery link; the
+// next checkpoint then covers the recovery.
+//
+// A checkpoint is written after every checkpointInterval records, and when
+// the sink is closed. See env.AuditChainCheckpointInterval for a sensible
+// default.
+func NewChainSink(path string, checkpointInterval int) (*ChainSink, error) {
+	tail, err := chainTail(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ChainSink{
+		file:               f,
+		seq:                tail.seq,
+		head:               tail.head,
+		checkpointInterval: checkpointInterval,
+	}
+	if tail.incomplete == nil {
+		return s, nil
+	}
+
+	log.WarnLn(
+		"audit: the last line of", path,
+		"is incomplete; continuing the chain after it",
+	)
+	if !tail.terminated {
+		if _, err := f.Write([]byte{'\n'}); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	if err := s.append(ChainEntry{Recovery: &Recovery{
+		Time:    time.Now().UTC(),
+		Skipped: chainHash(tail.incomplete),
+	}}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	s.sinceCheckpoint = 1
+	return s, nil
+}
+
+func (s *ChainSink) Write(e JournalEntry) error {
+	r := NewRecord(e)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.append(ChainEntry{Record: &r}); err != nil {
+		return err
+	}
+
+	s.sinceCheckpoint++
+	if s.checkpointInterval > 0 && s.sinceCheckpoint >= s.checkpointInterval {
+		return s.checkpoint()
+	}
+	return nil
+}
+
+func (s *ChainSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.sinceCheckpoint > 0 {
+		if err := s.checkpoint(); err != nil {
+			_ = s.file.Close()
+			return err
+		}
+	}
+	if err := s.file.Sync(); err != nil {
+		_ = s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// SignCheckpoints makes the sink sign every checkpoint that it writes from
+// now on with signer. Use VerifyChainWithKeys to verify a signed chain.
+func (s *ChainSink) SignCheckpoints(signer *Signer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.signer = signer
+}
+
+// Head returns the sequence number and the hash of the last link.
+func (s *ChainSink) Head() (uint64, string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.seq, s.head
+}
+
+func (s *ChainSink) checkpoint() error {
+	cp := Checkpoint{
+		Time:  time.Now().UTC(),
+		Count: s.seq,
+		Head:  s.head,
+	}
+	if s.signer != nil {
+		cp.KeyId = s.signer.KeyId()
+		cp.Signature = s.signer.Sign(cp.payload())
+	}
+	if err := s.append(ChainEntry{Checkpoint: &cp}); err != nil {
+		return err
+	}
+	s.sinceCheckpoint = 0
+	return nil
+}
+
+// append links ce to the chain and writes it. Must be called with s.mux held.
+func (s *ChainSink) append(ce ChainEntry) error {
+	ce.Seq = s.seq + 1
+	ce.Prev = s.head
+
+	entry, err := json.Marshal(ce)
+	if err != nil {
+		return err
+	}
+	hash := chainHash(entry)
+
+	b, err := json.Marshal(chainLink{Entry: entry, Hash: hash})
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	s.seq = ce.Seq
+	s.head = hash
+	return nil
+}
+
+func chainHash(entry []byte) string {
+	sum := sha256.Sum256(entry)
+	return hex.EncodeToString(sum[:])
+}
+
+// chainEnd is the end of a hash-chained journal.
+type chainEnd struct {
+	// Sequence number and hash of the last complete link.
+	seq  uint64
+	head string
+	// The last line, if it is not a complete link.
+	incomplete []byte
+	// True if the journal ends with a newline.
+	terminated bool
+}
+
+// chainTail returns the end of the journal at path. A journal that does not
+// exist yet is empty. Lines that cannot be decoded are an error, unless they
+// are the last line, or are followed by a link (which is a Recovery link in
+// a valid chain; VerifyChain checks that).
+func chainTail(path string) (chainEnd, error) {
+	end := chainEnd{head: GenesisHash, terminated: true}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return end, nil
+	}
+	if err != nil {
+		return chainEnd{}, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var malformed error
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		link, ce, err := decodeLink(scanner.Bytes())
+		if err != nil {
+			if malformed != nil {
+				return chainEnd{}, fmt.Errorf(
+					"audit: malformed line in the chain: %s", malformed.Error(),
+				)
+			}
+			malformed = err
+			end.incomplete = append([]byte(nil), scanner.Bytes()...)
+			continue
+		}
+		if end.incomplete != nil {
+			if ce.Recovery == nil {
+				return chainEnd{}, fmt.Errorf(
+					"audit: malformed line in the chain: %s", malformed.Error(),
+				)
+			}
+			end.incomplete = nil
+		}
+		malformed = nil
+		end.seq, end.head = ce.Seq, link.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return chainEnd{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return chainEnd{}, err
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+			return chainEnd{}, err
+		}
+		end.terminated = last[0] == '\n'
+	}
+	return end, nil
+}
+
+func decodeLink(b []byte) (chainLink, ChainEntry, error) {
+	var link chainLink
+	var ce ChainEntry
+	if err := json.Unmarshal(b, &link); err != nil {
+		return link, ce, err
+	}
+	if err := json.Unmarshal(link.Entry, &ce); err != nil {
+		return link, ce, err
+	}
+	return link, ce, nil
+}
+
+// BrokenLinkError describes the first link of a hash-chained journal that
+// fails verification.
+type BrokenLinkError struct {
+	// Line number in the journal, starting from 1.
+	Line int
+	// Sequence number of the link, if it could be decoded.
+	Seq    uint64
+	Reason string
+}
+
+func (e *BrokenLinkError) Error() string {
+	return fmt.Sprintf(
+		"audit: broken chain at line %d (seq %d): %s", e.Line, e.Seq, e.Reason,
+	)
+}
+
+// VerifyChain walks the hash-chained journal that r provides and checks every
+// link. It returns a *BrokenLinkError for the first link that does not
+// verify, nil if the whole chain is intact, and any other error if r cannot
+// be read.
+//
+// Note that VerifyChain cannot detect the removal of the trailing links of a
+// journal on its own; compare the result of the last checkpoint with a copy
+// kept elsewhere for that.
+func VerifyChain(r io.Reader) error {
+	return VerifyChainWithKeys(r, nil)
+}
+
+// VerifyChainWithKeys is like VerifyChain, but it also requires every
+// checkpoint to be signed by one of the keys in keys. If keys is nil, the
+// signatures are not checked.
+func VerifyChainWithKeys(r io.Reader, keys KeyRing) error {
+	seq, head := uint64(0), GenesisHash
+	line := 0
+
+	// An incomplete line, which must be followed by a Recovery link.
+	var incomplete []byte
+	var incompleteLine int
+	var incompleteErr error
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var link chainLink
+		var ce ChainEntry
+		err := json.Unmarshal(scanner.Bytes(), &link)
+		if err == nil {
+			err = json.Unmarshal(link.Entry, &ce)
+		}
+		if err != nil {
+			if incomplete != nil {
+				return &BrokenLinkError{Line: incompleteLine, Seq: seq + 1,
+					Reason: "malformed link: " + incompleteErr.Error()}
+			}
+			incomplete = append([]byte(nil), scanner.Bytes()...)
+			incompleteLine, incompleteErr = line, err
+			continue
+		}
+
+		if incomplete != nil && ce.Recovery == nil {
+			return &BrokenLinkError{Line: incompleteLine, Seq: seq + 1,
+				Reason: "malformed link: " + incompleteErr.Error()}
+		}
+		if hash := chainHash(link.Entry); hash != link.Hash {
+			return &BrokenLinkError{Line: line, Seq: ce.Seq,
+				Reason: "hash mismatch; the entry has been modified"}
+		}
+		if ce.Seq != seq+1 {
+			return &BrokenLinkError{Line: line, Seq: ce.Seq,
+				Reason: fmt.Sprintf("expected seq %d", seq+1)}
+		}
+		if ce.Prev != head {
+			return &BrokenLinkError{Line: line, Seq: ce.Seq,
+				Reason: "prev does not match the hash of the previous link"}
+		}
+		set := 0
+		for _, p := range []bool{
+			ce.Record != nil, ce.Checkpoint != nil, ce.Recovery != nil,
+		} {
+			if p {
+				set++
+			}
+		}
+		if set != 1 {
+			return &BrokenLinkError{Line: line, Seq: ce.Seq, Reason: "entry must " +
+				"have exactly one of a record, a checkpoint, or a recovery"}
+		}
+		if incomplete != nil {
+			if ce.Recovery.Skipped != chainHash(incomplete) {
+				return &BrokenLinkError{Line: incompleteLine, Seq: seq + 1,
+					Reason: "the incomplete line does not match its recovery"}
+			}
+			incomplete = nil
+		} else if ce.Recovery != nil {
+			return &BrokenLinkError{Line: line, Seq: ce.Seq,
+				Reason: "recovery without an incomplete line"}
+		}
+		if cp := ce.Checkpoint; cp != nil {
+			if cp.Count != seq || cp.Head != head {
+				return &BrokenLinkError{Line: line, Seq: ce.Seq,
+					Reason: "checkpoint does not match the chain"}
+			}
+			if keys != nil {
+				err := keys.Verify(cp.KeyId, cp.payload(), cp.Signature)
+				if err != nil {
+					return &BrokenLinkError{Line: line, Seq: ce.Seq,
+						Reason: "checkpoint: " + strings.TrimPrefix(err.Error(), "audit: ")}
+				}
+			}
+		}
+
+		seq, head = ce.Seq, link.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if incomplete != nil {
+		// A crash has left the last line incomplete; NewChainSink
+		// recovers from it when it opens the journal.
+		return &BrokenLinkError{Line: incompleteLine, Seq: seq + 1,
+			Reason: "incomplete last line: " + incompleteErr.Error()}
+	}
+	return nil
+}
+
+// VerifyChainFile is like VerifyChain, but it reads the journal at path.
+func VerifyChainFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return VerifyChain(f)
+}
@@ -0,0 +1,319 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeChain appends the entries from..to to the chain at path, and closes
+// the sink.
+func writeChain(t *testing.T, path string, interval, from, to int) {
+	t.Helper()
+	s, err := audit.NewChainSink(path, interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEntries(t, s, from, to)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func chainLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Split(bytes.TrimSuffix(b, []byte("\n")), []byte("\n"))
+}
+
+func writeChainLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+	b := append(bytes.Join(lines, []byte("\n")), '\n')
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type rawLink struct {
+	Entry json.RawMessage `json:"entry"`
+	Hash  string          `json:"hash"`
+}
+
+func chainEntry(t *testing.T, line []byte) audit.ChainEntry {
+	t.Helper()
+	var link rawLink
+	var ce audit.ChainEntry
+	if err := json.Unmarshal(line, &link); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(link.Entry, &ce); err != nil {
+		t.Fatal(err)
+	}
+	return ce
+}
+
+// relink encodes ce as a link with a correct hash, as someone who edits the
+// journal on purpose would.
+func relink(t *testing.T, ce audit.ChainEntry) []byte {
+	t.Helper()
+	entry, err := json.Marshal(ce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(entry)
+	b, err := json.Marshal(rawLink{Entry: entry, Hash: hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func brokenLine(t *testing.T, err error) int {
+	t.Helper()
+	var broken *audit.BrokenLinkError
+	if !errors.As(err, &broken) {
+		t.Fatalf("want a *BrokenLinkError, got %v", err)
+	}
+	return broken.Line
+}
+
+func TestChainSinkWritesCheckpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.log")
+	writeChain(t, path, 3, 0, 5)
+
+	lines := chainLines(t, path)
+	if len(lines) != 7 {
+		t.Fatalf("want 7 links, got %d", len(lines))
+	}
+	prev := audit.GenesisHash
+	for i, line := range lines {
+		ce := chainEntry(t, line)
+		if ce.Seq != uint64(i+1) || ce.Prev != prev {
+			t.Errorf("line %d: seq %d, prev %q", i+1, ce.Seq, ce.Prev)
+		}
+		var link rawLink
+		_ = json.Unmarshal(line, &link)
+		prev = link.Hash
+
+		switch i + 1 {
+		case 4, 7:
+			cp := ce.Checkpoint
+			if cp == nil || ce.Record != nil {
+				t.Fatalf("line %d: want a checkpoint", i+1)
+			}
+			if cp.Count != uint64(i) {
+				t.Errorf("line %d: checkpoint count %d", i+1, cp.Count)
+			}
+		default:
+			if ce.Record == nil || ce.Checkpoint != nil {
+				t.Errorf("line %d: want a record", i+1)
+			}
+		}
+	}
+
+	if err := audit.VerifyChainFile(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChainSinkContinuesAnExistingChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.log")
+	writeChain(t, path, 0, 0, 2)
+
+	s, err := audit.NewChainSink(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq, _ := s.Head(); seq != 3 {
+		t.Errorf("want the chain to continue from seq 3, got %d", seq)
+	}
+	writeEntries(t, s, 2, 4)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := audit.VerifyChainFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(chainLines(t, path)); n != 6 {
+		t.Errorf("want 6 links, got %d", n)
+	}
+	p, err := audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1,cid-2,cid-3" {
+		t.Errorf("got records %s", got)
+	}
+}
+
+func TestVerifyChainDetectsTampering(t *testing.T) {
+	// Lines 1-3 and 5-6 are records; 4 and 7 are checkpoints.
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, lines [][]byte) [][]byte
+		line   int
+	}{
+		{
+			name: "edited entry",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte("cid-1"), []byte("cid-9"), 1)
+				return lines
+			},
+			line: 2,
+		},
+		{
+			name: "edited entry with a recomputed hash",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				ce := chainEntry(t, lines[1])
+				ce.Record.CorrelationId = "cid-9"
+				lines[1] = relink(t, ce)
+				return lines
+			},
+			line: 3,
+		},
+		{
+			name: "deleted entry",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			line: 2,
+		},
+		{
+			name: "reordered entries",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				lines[4], lines[5] = lines[5], lines[4]
+				return lines
+			},
+			line: 5,
+		},
+		{
+			name: "edited checkpoint with a recomputed hash",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				ce := chainEntry(t, lines[3])
+				ce.Checkpoint.Count = 2
+				lines[3] = relink(t, ce)
+				return lines
+			},
+			line: 4,
+		},
+		{
+			name: "link with both a record and a checkpoint",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				ce := chainEntry(t, lines[3])
+				ce.Record = chainEntry(t, lines[2]).Record
+				lines[3] = relink(t, ce)
+				return lines
+			},
+			line: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chain.log")
+			writeChain(t, path, 3, 0, 5)
+			writeChainLines(t, path, tt.tamper(t, chainLines(t, path)))
+
+			err := audit.VerifyChainFile(path)
+			if line := brokenLine(t, err); line != tt.line {
+				t.Errorf("want line %d, got %v", tt.line, err)
+			}
+		})
+	}
+}
+
+func TestChainSinkRecoversFromAnIncompleteLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.log")
+	writeChain(t, path, 0, 0, 3)
+
+	// A crash in the middle of a write leaves the start of a link behind.
+	lines := chainLines(t, path)
+	torn := lines[0][:len(lines[0])/2]
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(torn); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = audit.VerifyChainFile(path)
+	if line := brokenLine(t, err); line != 5 {
+		t.Errorf("want line 5, got %v", err)
+	}
+
+	writeChain(t, path, 0, 3, 4)
+	if err := audit.VerifyChainFile(path); err != nil {
+		t.Fatal(err)
+	}
+	lines = chainLines(t, path)
+	if len(lines) != 8 || !bytes.Equal(lines[4], torn) {
+		t.Fatalf("want the incomplete line to be kept, got %q", lines)
+	}
+	if ce := chainEntry(t, lines[5]); ce.Recovery == nil || ce.Seq != 5 {
+		t.Errorf("want a recovery link after the incomplete line, got %+v", ce)
+	}
+	if ce := chainEntry(t, lines[7]); ce.Checkpoint == nil || ce.Checkpoint.Count != 6 {
+		t.Errorf("want a checkpoint that covers the recovery, got %+v", ce)
+	}
+
+	// The recovered chain can be continued again.
+	writeChain(t, path, 0, 4, 5)
+	if err := audit.VerifyChainFile(path); err != nil {
+		t.Fatal(err)
+	}
+	p, err := audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1,cid-2,cid-3,cid-4" {
+		t.Errorf("got records %s", got)
+	}
+
+	// The incomplete line cannot be replaced without breaking the chain.
+	lines = chainLines(t, path)
+	lines[4] = []byte(strings.Replace(string(lines[4]), "seq", "qes", 1))
+	writeChainLines(t, path, lines)
+	err = audit.VerifyChainFile(path)
+	if line := brokenLine(t, err); line != 5 {
+		t.Errorf("want line 5, got %v", err)
+	}
+}
+
+func TestNewChainSinkRejectsAMalformedLineInTheMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.log")
+	writeChain(t, path, 0, 0, 3)
+	lines := chainLines(t, path)
+	lines[1] = lines[1][:len(lines[1])/2]
+	writeChainLines(t, path, lines)
+
+	if _, err := audit.NewChainSink(path, 0); err == nil {
+		t.Fatal("want an error")
+	}
+	err := audit.VerifyChainFile(path)
+	if line := brokenLine(t, err); line != 2 {
+		t.Errorf("want line 2, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/crypto"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const CloudEventsSpecVersion = "1.0"
+
+// CloudEventsTypePrefix prefixes the CloudEvents type of every audit event.
+const CloudEventsTypePrefix = "ist.aegis.audit."
+
+// CloudEventsMode is the content mode that CloudEventsSink uses to send the
+// events over HTTP.
+type CloudEventsMode string
+
+// CloudEventsStructured sends the whole event as the request body, with the
+// `application/cloudevents+json` content type.
+var CloudEventsStructured CloudEventsMode = "structured"
+
+// CloudEventsBinary sends the event attributes as `ce-` prefixed headers,
+// and the Record as the request body.
+var CloudEventsBinary CloudEventsMode = "binary"
+
+// CloudEvent is a CloudEvents 1.0 event in its JSON format, that carries an
+// audit Record as its data.
+type CloudEvent struct {
+	SpecVersion     string `json:"specversion"`
+	Id              string `json:"id"`
+	Source          string `json:"source"`
+	Type            string `json:"type"`
+	Subject         string `json:"subject,omitempty"`
+	Time            string `json:"time,omitempty"`
+	DataContentType string `json:"datacontenttype"`
+	// Extension attribute that holds the correlation id of the entry.
+	CorrelationId string `json:"correlationid,omitempty"`
+	Data          Record `json:"data"`
+}
+
+// CloudEventType maps an audit event to its CloudEvents type. For example,
+// EventOk ("aegis-ok") maps to "ist.aegis.audit.ok", and EventBadSvid
+// ("aegis-bad-svid") maps to "ist.aegis.audit.bad-svid".
+func CloudEventType(e Event) string {
+	return CloudEventsTypePrefix + strings.TrimPrefix(string(e), "aegis-")
+}
+
+// NewCloudEvent converts e into a CloudEvent. source identifies the component
+// that produces the event, such as "aegis-safe".
+//
+// The SVID of the entry becomes the subject of the event.
+func NewCloudEvent(e JournalEntry, source string) (CloudEvent, error) {
+	id, err := crypto.RandomStringSecure(16)
+	if err != nil {
+		return CloudEvent{}, err
+	}
+
+	r := NewRecord(e)
+	ce := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Id:              id,
+		Source:          source,
+		Type:            CloudEventType(r.Event),
+		Subject:         r.Svid,
+		DataContentType: "application/json",
+		CorrelationId:   r.CorrelationId,
+		Data:            r,
+	}
+	if !r.Timestamp.IsZero() {
+		ce.Time = r.Timestamp.Format(time.RFC3339Nano)
+	}
+	return ce, nil
+}
+
+// CloudEventsConfig configures a CloudEventsSink.
+type CloudEventsConfig struct {
+	// URL to POST the events to.
+	Endpoint string
+	// Defaults to CloudEventsStructured.
+	Mode CloudEventsMode
+	// Defaults to "aegis-safe".
+	Source string
+	// Defaults to an http.Client with a 5-second timeout.
+	Client *http.Client
+}
+
+// CloudEventsSink POSTs every audit journal entry, as a CloudEvent, to an
+// HTTP endpoint. Any response status other than 2xx is a failed write.
+type CloudEventsSink struct {
+	config CloudEventsConfig
+}
+
+// NewCloudEventsSink creates a CloudEventsSink.
+func NewCloudEventsSink(c CloudEventsConfig) (*CloudEventsSink, error) {
+	switch c.Mode {
+	case "":
+		c.Mode = CloudEventsStructured
+	case CloudEventsStructured, CloudEventsBinary:
+	default:
+		return nil, fmt.Errorf("audit: unsupported CloudEvents mode %q", c.Mode)
+	}
+	if c.Source == "" {
+		c.Source = "aegis-safe"
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &CloudEventsSink{config: c}, nil
+}
+
+func (s *CloudEventsSink) Write(e JournalEntry) error {
+	ce, err := NewCloudEvent(e, s.config.Source)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request(ce)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf(
+			"audit: CloudEvents endpoint responded with %s", res.Status,
+		)
+	}
+	return nil
+}
+
+func (s *CloudEventsSink) Close() error {
+	s.config.Client.CloseIdleConnections()
+	return nil
+}
+
+func (s *CloudEventsSink) request(ce CloudEvent) (*http.Request, error) {
+	if s.config.Mode == CloudEventsStructured {
+		body, err := json.Marshal(ce)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(
+			http.MethodPost, s.config.Endpoint, bytes.NewReader(body),
+		)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+		return req, nil
+	}
+
+	body, err := json.Marshal(ce.Data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(
+		http.MethodPost, s.config.Endpoint, bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", ce.DataContentType)
+	req.Header.Set("ce-specversion", ce.SpecVersion)
+	req.Header.Set("ce-id", cloudEventsHeader(ce.Id))
+	req.Header.Set("ce-source", cloudEventsHeader(ce.Source))
+	req.Header.Set("ce-type", cloudEventsHeader(ce.Type))
+	if ce.Subject != "" {
+		req.Header.Set("ce-subject", cloudEventsHeader(ce.Subject))
+	}
+	if ce.Time != "" {
+		req.Header.Set("ce-time", ce.Time)
+	}
+	if ce.CorrelationId != "" {
+		req.Header.Set("ce-correlationid", cloudEventsHeader(ce.CorrelationId))
+	}
+	return req, nil
+}
+
+// cloudEventsHeader percent-encodes the UTF-8 bytes of v that the HTTP
+// binding of CloudEvents requires encoding in header values: spaces, `"`,
+// `%`, and everything outside of printable ASCII.
+func cloudEventsHeader(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,215 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"encoding/json"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventReceiver is a stand-in for a CloudEvents endpoint; it records the
+// requests that it receives.
+type eventReceiver struct {
+	mux      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func (e *eventReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, body)
+	if e.status != 0 {
+		w.WriteHeader(e.status)
+	}
+}
+
+func newCloudEventsSink(
+	t *testing.T, mode audit.CloudEventsMode,
+) (*audit.CloudEventsSink, *eventReceiver) {
+	t.Helper()
+	e := &eventReceiver{}
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	s, err := audit.NewCloudEventsSink(audit.CloudEventsConfig{
+		Endpoint: server.URL,
+		Mode:     mode,
+		Source:   "aegis-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s, e
+}
+
+var cloudEventsEntry = audit.JournalEntry{
+	CorrelationId: "cid-1",
+	Method:        http.MethodPost,
+	Url:           "/secrets",
+	Svid:          "spiffe://aegis.ist/workload/example",
+	Event:         audit.EventBadSvid,
+	Time:          time.Date(2023, 4, 1, 10, 20, 30, 5, time.UTC),
+}
+
+func TestCloudEventsStructured(t *testing.T) {
+	s, e := newCloudEventsSink(t, audit.CloudEventsStructured)
+	if err := s.Write(cloudEventsEntry); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(e.requests))
+	}
+
+	r := e.requests[0]
+	if r.Method != http.MethodPost {
+		t.Errorf("method: got %s", r.Method)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/cloudevents+json" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+	for k := range r.Header {
+		if strings.HasPrefix(strings.ToLower(k), "ce-") {
+			t.Errorf("structured mode has sent the header %s", k)
+		}
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(e.bodies[0], &raw); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"specversion":     "1.0",
+		"source":          "aegis-test",
+		"type":            "ist.aegis.audit.bad-svid",
+		"subject":         "spiffe://aegis.ist/workload/example",
+		"time":            "2023-04-01T10:20:30.000000005Z",
+		"datacontenttype": "application/json",
+		"correlationid":   "cid-1",
+	}
+	for k, v := range want {
+		if raw[k] != v {
+			t.Errorf("%s: got %v, want %q", k, raw[k], v)
+		}
+	}
+	if id, _ := raw["id"].(string); id == "" {
+		t.Error("id is missing")
+	}
+
+	var ce audit.CloudEvent
+	if err := json.Unmarshal(e.bodies[0], &ce); err != nil {
+		t.Fatal(err)
+	}
+	if ce.Data.CorrelationId != "cid-1" || ce.Data.Event != audit.EventBadSvid ||
+		ce.Data.Url != "/secrets" {
+		t.Errorf("data: got %+v", ce.Data)
+	}
+}
+
+func TestCloudEventsBinary(t *testing.T) {
+	s, e := newCloudEventsSink(t, audit.CloudEventsBinary)
+	if err := s.Write(cloudEventsEntry); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(e.requests))
+	}
+
+	h := e.requests[0].Header
+	want := map[string]string{
+		"Content-Type":     "application/json",
+		"ce-specversion":   "1.0",
+		"ce-source":        "aegis-test",
+		"ce-type":          "ist.aegis.audit.bad-svid",
+		"ce-subject":       "spiffe://aegis.ist/workload/example",
+		"ce-time":          "2023-04-01T10:20:30.000000005Z",
+		"ce-correlationid": "cid-1",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s: got %q, want %q", k, got, v)
+		}
+	}
+	if h.Get("ce-id") == "" {
+		t.Error("ce-id is missing")
+	}
+
+	// The body is the data of the event; the Record.
+	var r audit.Record
+	if err := json.Unmarshal(e.bodies[0], &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.CorrelationId != "cid-1" || r.Event != audit.EventBadSvid ||
+		r.Svid != cloudEventsEntry.Svid {
+		t.Errorf("body: got %+v", r)
+	}
+	var raw map[string]any
+	_ = json.Unmarshal(e.bodies[0], &raw)
+	if _, ok := raw["specversion"]; ok {
+		t.Errorf("binary mode has sent the envelope in the body: %s", e.bodies[0])
+	}
+}
+
+func TestCloudEventsBinaryEncodesHeaders(t *testing.T) {
+	s, e := newCloudEventsSink(t, audit.CloudEventsBinary)
+	entry := cloudEventsEntry
+	entry.CorrelationId = `a b"c%dé`
+	entry.Time = time.Time{}
+	if err := s.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	h := e.requests[0].Header
+	if got, want := h.Get("ce-correlationid"), "a%20b%22c%25d%C3%A9"; got != want {
+		t.Errorf("ce-correlationid: got %q, want %q", got, want)
+	}
+	if _, ok := h["Ce-Time"]; ok {
+		t.Errorf("ce-time: got %q for an entry without a time", h.Get("ce-time"))
+	}
+}
+
+func TestCloudEventsErrorStatus(t *testing.T) {
+	s, e := newCloudEventsSink(t, audit.CloudEventsStructured)
+	e.status = http.StatusBadRequest
+	err := s.Write(cloudEventsEntry)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("got %v, want the status of the endpoint", err)
+	}
+}
+
+func TestCloudEventType(t *testing.T) {
+	tests := map[audit.Event]string{
+		audit.EventOk:                  "ist.aegis.audit.ok",
+		audit.EventBadSvid:             "ist.aegis.audit.bad-svid",
+		audit.EventRequestTypeMismatch: "ist.aegis.audit.request-type-mismatch",
+	}
+	for e, want := range tests {
+		if got := audit.CloudEventType(e); got != want {
+			t.Errorf("%s: got %s, want %s", e, got, want)
+		}
+	}
+}
+
+func TestCloudEventsUnsupportedMode(t *testing.T) {
+	_, err := audit.NewCloudEventsSink(audit.CloudEventsConfig{Mode: "batched"})
+	if err == nil {
+		t.Error("got no error for an unsupported mode")
+	}
+}
@@ -0,0 +1,17 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+// TrackedSvids returns the number of SVIDs that d keeps hits for, and the
+// number of SVIDs that it keeps as denied.
+func TrackedSvids(d *Detector) (int, int) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return len(d.hits), len(d.denied)
+}
@@ -0,0 +1,53 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"os"
+	"sync"
+)
+
+// FileSink appends audit journal entries to a file, as one JSON Record
+// per line.
+type FileSink struct {
+	mux  sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens (or creates) the file at path for appending, and returns
+// a Sink that writes to it. The file is created with owner-only permissions.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (s *FileSink) Write(e JournalEntry) error {
+	b, err := encode(e)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, err = s.file.Write(b)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.file.Sync(); err != nil {
+		_ = s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
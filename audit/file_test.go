@@ -0,0 +1,78 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSinkAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for _, r := range [][2]int{{0, 2}, {2, 3}} {
+		s, err := audit.NewFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeEntries(t, s, r[0], r[1])
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1,cid-2" {
+		t.Errorf("want reopening to keep the records, got %s", got)
+	}
+}
+
+func TestFileSinkPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	s, err := audit.NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("got permissions %o, want 600", perm)
+	}
+}
+
+func TestFileSinkCannotBeWrittenAfterClose(t *testing.T) {
+	s, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(audit.JournalEntry{Event: audit.EventOk}); err == nil {
+		t.Error("want an error")
+	}
+}
+
+func TestNewFileSinkFailsForAMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "audit.log")
+	if _, err := audit.NewFileSink(path); err == nil {
+		t.Error("want an error")
+	}
+}
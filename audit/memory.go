@@ -0,0 +1,51 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import "sync"
+
+// MemorySink keeps audit journal entries in memory. It is mostly useful
+// for diagnostics and for testing.
+type MemorySink struct {
+	mux     sync.Mutex
+	entries []JournalEntry
+}
+
+// NewMemorySink creates an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Write(e JournalEntry) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// Entries returns a copy of the entries written to the sink so far, in the
+// order they were written.
+func (s *MemorySink) Entries() []JournalEntry {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entries := make([]JournalEntry, len(s.entries))
+	copy(entries, s.entries)
+	return entries
+}
+
+// Reset discards all entries that the sink holds.
+func (s *MemorySink) Reset() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.entries = nil
+}
@@ -0,0 +1,125 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"context"
+	"github.com/zerotohero-dev/aegis-core/crypto"
+	"github.com/zerotohero-dev/aegis-core/log"
+	"net/http"
+)
+
+type correlationIdKey struct{}
+
+// WithCorrelationId returns a copy of ctx that carries id.
+func WithCorrelationId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIdKey{}, id)
+}
+
+// CorrelationId returns the correlation id that ctx carries, or an empty
+// string if there is none.
+func CorrelationId(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIdKey{}).(string)
+	return id
+}
+
+// PeerSvid returns the SPIFFE ID in the client certificate of the TLS
+// connection of r, or an empty string if there is none.
+func PeerSvid(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	for _, u := range r.TLS.PeerCertificates[0].URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+// Middleware wraps next, so that every request it serves is audited:
+//
+//   - A correlation id is generated, and put into the request context;
+//     handlers can get it with CorrelationId, to use in their own entries.
+//   - The SVID of the peer is extracted from the TLS connection.
+//   - An EventEnter entry is logged before next is called, and an EventExit
+//     entry with the response status is logged after next returns. If next
+//     panics, the EventExit entry has status 500, and the panic is
+//     propagated.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := crypto.RandomString(8)
+		if err != nil {
+			log.WarnLn("audit: failed to generate correlation id", err.Error())
+		}
+
+		svid := PeerSvid(r)
+		Log(JournalEntry{
+			CorrelationId: id,
+			Method:        r.Method,
+			Url:           r.RequestURI,
+			Svid:          svid,
+			Event:         EventEnter,
+		})
+
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			status := rec.status()
+			p := recover()
+			if p != nil {
+				status = http.StatusInternalServerError
+			}
+			Log(JournalEntry{
+				CorrelationId: id,
+				Method:        r.Method,
+				Url:           r.RequestURI,
+				Svid:          svid,
+				Event:         EventExit,
+				Status:        status,
+			})
+			if p != nil {
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rec, r.WithContext(WithCorrelationId(r.Context(), id)))
+	})
+}
+
+// statusRecorder remembers the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *statusRecorder) status() int {
+	if s.code == 0 {
+		// The handler did not write anything; net/http responds with 200.
+		return http.StatusOK
+	}
+	return s.code
+}
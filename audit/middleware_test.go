@@ -0,0 +1,91 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serve(h http.Handler) (p any) {
+	defer func() { p = recover() }()
+	h.ServeHTTP(
+		httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/secrets", nil),
+	)
+	return nil
+}
+
+func expectExit(t *testing.T, sink *audit.MemorySink, status int) {
+	t.Helper()
+	entries := sink.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+	enter, exit := entries[0], entries[1]
+	if enter.Event != audit.EventEnter || exit.Event != audit.EventExit {
+		t.Errorf("events: got %s, %s", enter.Event, exit.Event)
+	}
+	if exit.CorrelationId == "" || exit.CorrelationId != enter.CorrelationId {
+		t.Errorf("correlation ids: got %q, %q",
+			enter.CorrelationId, exit.CorrelationId)
+	}
+	if exit.Method != http.MethodPost || exit.Url != "/secrets" {
+		t.Errorf("request: got %s %s", exit.Method, exit.Url)
+	}
+	if exit.Status != status {
+		t.Errorf("status: got %d, want %d", exit.Status, status)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	sink := audit.NewMemorySink()
+	withSink(t, "middleware-test", sink)
+
+	var id string
+	p := serve(audit.Middleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id = audit.CorrelationId(r.Context())
+			w.WriteHeader(http.StatusNotFound)
+		},
+	)))
+	if p != nil {
+		t.Fatalf("unexpected panic: %v", p)
+	}
+	expectExit(t, sink, http.StatusNotFound)
+	if got := sink.Entries()[1].CorrelationId; got != id {
+		t.Errorf("the handler got the correlation id %q, want %q", id, got)
+	}
+}
+
+func TestMiddlewareLogsExitOnPanic(t *testing.T) {
+	for name, write := range map[string]bool{
+		"before writing": false,
+		"after writing":  true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			sink := audit.NewMemorySink()
+			withSink(t, "middleware-test", sink)
+
+			p := serve(audit.Middleware(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					if write {
+						w.WriteHeader(http.StatusOK)
+					}
+					panic("boom")
+				},
+			)))
+			if p != "boom" {
+				t.Errorf("got panic %v, want the panic of the handler", p)
+			}
+			expectExit(t, sink, http.StatusInternalServerError)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/zerotohero-dev/aegis-core/env"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PipelineConfig configures the asynchronous audit pipeline.
+type PipelineConfig struct {
+	// Number of entries that can wait for delivery.
+	QueueSize int
+	// Number of goroutines that deliver the queued entries to the sinks.
+	// With more than one worker, entries may reach the sinks out of order.
+	Workers int
+	// If true, Log drops the entry when the queue is full. Otherwise, Log
+	// blocks until there is room in the queue.
+	DropWhenFull bool
+}
+
+// PipelineConfigFromEnv returns the pipeline configuration that the
+// environment specifies; see env.AuditQueueSize, env.AuditWorkerCount, and
+// env.AuditDropWhenQueueFull.
+func PipelineConfigFromEnv() PipelineConfig {
+	return PipelineConfig{
+		QueueSize:    env.AuditQueueSize(),
+		Workers:      env.AuditWorkerCount(),
+		DropWhenFull: env.AuditDropWhenQueueFull(),
+	}
+}
+
+// Stats are the delivery counters of the audit package. The counters are
+// cumulative since the process started.
+type Stats struct {
+	// Number of successful writes to a sink. An entry that is written to
+	// three sinks counts three times.
+	Delivered uint64
+	// Number of entries that were dropped because the queue was full.
+	Dropped uint64
+	// Number of failed writes to a sink.
+	Failed uint64
+	// Number of entries that are waiting in the queue, or are being
+	// delivered at the moment.
+	Pending int64
+}
+
+var delivered, dropped, failed atomic.Uint64
+var pending atomic.Int64
+
+// CurrentStats returns a snapshot of the delivery counters.
+func CurrentStats() Stats {
+	return Stats{
+		Delivered: delivered.Load(),
+		Dropped:   dropped.Load(),
+		Failed:    failed.Load(),
+		Pending:   pending.Load(),
+	}
+}
+
+// sequence numbers the queued entries, and tracks their delivery, so that
+// Flush can wait for the entries that were queued before it was called,
+// without waiting for the ones that keep arriving under load.
+type sequence struct {
+	mux sync.Mutex
+	// Sequence number of the last queued entry.
+	last uint64
+	// Every entry up to and including this one has been delivered.
+	delivered uint64
+	// Entries after `delivered` that were delivered out of order, by
+	// another worker.
+	ahead map[uint64]bool
+}
+
+var queued = sequence{ahead: map[uint64]bool{}}
+
+func (s *sequence) next() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.last++
+	return s.last
+}
+
+// done marks the entry n as delivered; dropped entries count as delivered.
+func (s *sequence) done(n uint64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.ahead[n] = true
+	for s.ahead[s.delivered+1] {
+		delete(s.ahead, s.delivered+1)
+		s.delivered++
+	}
+}
+
+func (s *sequence) current() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.last
+}
+
+func (s *sequence) reached(n uint64) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.delivered >= n
+}
+
+type queuedEntry struct {
+	seq   uint64
+	entry JournalEntry
+}
+
+type pipeline struct {
+	queue        chan queuedEntry
+	dropWhenFull bool
+	workers      sync.WaitGroup
+}
+
+// current is the running pipeline; nil when Log delivers synchronously.
+var current *pipeline
+var pipelineMux sync.RWMutex
+
+// ErrPipelineRunning is returned when StartPipeline is called while a
+// pipeline is already running.
+var ErrPipelineRunning = errors.New("audit: pipeline is already running")
+
+// StartPipeline makes Log queue the entries, and deliver them to the sinks
+// asynchronously, in the background. Until StartPipeline is called (and
+// after StopPipeline returns), Log delivers entries synchronously.
+func StartPipeline(c PipelineConfig) error {
+	if c.QueueSize < 1 {
+		c.QueueSize = 1
+	}
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+
+	pipelineMux.Lock()
+	defer pipelineMux.Unlock()
+	if current != nil {
+		return ErrPipelineRunning
+	}
+
+	p := &pipeline{
+		queue:        make(chan queuedEntry, c.QueueSize),
+		dropWhenFull: c.DropWhenFull,
+	}
+	for i := 0; i < c.Workers; i++ {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for q := range p.queue {
+				dispatch(q.entry)
+				pending.Add(-1)
+				queued.done(q.seq)
+			}
+		}()
+	}
+	current = p
+	return nil
+}
+
+// Flush blocks until every entry that was queued before the call is
+// delivered, or ctx is done. Entries that are queued after the call are not
+// waited for, so Flush returns even if Log is called continuously.
+func Flush(ctx context.Context) error {
+	target := queued.current()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for !queued.reached(target) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// StopPipeline stops accepting new entries into the queue, and waits until
+// the queued entries are delivered, or ctx is done. Entries that are logged
+// after StopPipeline is called are delivered synchronously.
+//
+// Call StopPipeline during shutdown, before closing the sinks.
+func StopPipeline(ctx context.Context) error {
+	pipelineMux.Lock()
+	p := current
+	current = nil
+	if p != nil {
+		close(p.queue)
+	}
+	pipelineMux.Unlock()
+
+	if p == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue hands e over to the running pipeline. It returns false if there
+// is no running pipeline, in which case the caller delivers e itself.
+func enqueue(e JournalEntry) bool {
+	pipelineMux.RLock()
+	defer pipelineMux.RUnlock()
+
+	p := current
+	if p == nil {
+		return false
+	}
+
+	pending.Add(1)
+	q := queuedEntry{seq: queued.next(), entry: e}
+	if !p.dropWhenFull {
+		p.queue <- q
+		return true
+	}
+
+	select {
+	case p.queue <- q:
+	default:
+		pending.Add(-1)
+		dropped.Add(1)
+		queued.done(q.seq)
+	}
+	return true
+}
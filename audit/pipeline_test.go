@@ -0,0 +1,139 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowSink is a MemorySink that takes a while to write every entry.
+type slowSink struct {
+	*audit.MemorySink
+}
+
+func (s slowSink) Write(e audit.JournalEntry) error {
+	time.Sleep(100 * time.Microsecond)
+	return s.MemorySink.Write(e)
+}
+
+// withSink registers s as the only sink, until the test ends.
+func withSink(t *testing.T, name string, s audit.Sink) {
+	_ = audit.UnregisterSink(audit.SinkNameStdout)
+	audit.RegisterSink(name, s)
+	t.Cleanup(func() {
+		_ = audit.UnregisterSink(name)
+		audit.RegisterSink(audit.SinkNameStdout, audit.NewStdoutSink())
+	})
+}
+
+func TestFlushUnderSteadyLoad(t *testing.T) {
+	sink := slowSink{audit.NewMemorySink()}
+	withSink(t, "pipeline-test-load", sink)
+
+	if err := audit.StartPipeline(audit.PipelineConfig{
+		QueueSize: 32, Workers: 4,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = audit.StopPipeline(context.Background()) }()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var logged atomic.Int64
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				audit.Log(audit.JournalEntry{
+					CorrelationId: fmt.Sprintf("load-%d", logged.Add(1)),
+				})
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	// Let the queue fill up.
+	for logged.Load() < 100 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		audit.Log(audit.JournalEntry{CorrelationId: fmt.Sprintf("before-%d", i)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := audit.Flush(ctx); err != nil {
+		t.Fatalf("Flush has not returned under load: %s", err.Error())
+	}
+
+	found := 0
+	for _, e := range sink.Entries() {
+		if strings.HasPrefix(e.CorrelationId, "before-") {
+			found++
+		}
+	}
+	if found != 10 {
+		t.Errorf("got %d of the entries logged before Flush, want 10", found)
+	}
+}
+
+func TestFlushCountsDroppedEntries(t *testing.T) {
+	release := make(chan struct{})
+	sink := &blockingSink{MemorySink: audit.NewMemorySink(), release: release}
+	withSink(t, "pipeline-test-drop", sink)
+
+	if err := audit.StartPipeline(audit.PipelineConfig{
+		QueueSize: 1, Workers: 1, DropWhenFull: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = audit.StopPipeline(context.Background()) }()
+
+	dropped := audit.CurrentStats().Dropped
+	for i := 0; i < 10; i++ {
+		audit.Log(audit.JournalEntry{CorrelationId: fmt.Sprintf("drop-%d", i)})
+	}
+	if audit.CurrentStats().Dropped == dropped {
+		t.Fatal("no entries were dropped")
+	}
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := audit.Flush(ctx); err != nil {
+		t.Fatalf("Flush has waited for dropped entries: %s", err.Error())
+	}
+}
+
+// blockingSink is a MemorySink whose writes block until release is closed.
+type blockingSink struct {
+	*audit.MemorySink
+	release chan struct{}
+}
+
+func (s *blockingSink) Write(e audit.JournalEntry) error {
+	<-s.release
+	return s.MemorySink.Write(e)
+}
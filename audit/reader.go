@@ -0,0 +1,310 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Reader reads audit records back from a journal. It understands the output
+// of every file-based sink in this package: plain records (StdoutSink,
+// FileSink), hash-chained journals (ChainSink), and signed journals
+// (SignedSink). Checkpoints, and lines that are not audit records (such as
+// the other output of a container), are skipped. So are lines longer than
+// 1 MiB, which no sink in this package writes.
+//
+// Reader does not verify the journal; see VerifyChain and VerifySigned.
+type Reader struct {
+	reader *bufio.Reader
+	line   []byte
+}
+
+// NewReader creates a Reader that reads from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// journalLine has the fields that tell the journal formats apart.
+type journalLine struct {
+	Version int             `json:"version"`
+	Entry   json.RawMessage `json:"entry"`
+	Record  json.RawMessage `json:"record"`
+}
+
+// Next returns the next record in the journal. It returns io.EOF when there
+// are no more records.
+func (r *Reader) Next() (Record, error) {
+	for {
+		line, tooLong, err := r.readLine()
+		if err != nil {
+			return Record{}, err
+		}
+		if tooLong {
+			continue
+		}
+		if rec, ok := parseLine(line); ok {
+			return rec, nil
+		}
+	}
+}
+
+// readLine returns the next line, without its line ending, and io.EOF if
+// there are no more lines. Lines longer than maxLineSize are read through,
+// but not kept; the second result is true for them.
+func (r *Reader) readLine() ([]byte, bool, error) {
+	r.line = r.line[:0]
+	tooLong := false
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if !tooLong {
+			r.line = append(r.line, chunk...)
+			if len(trimLineEnding(r.line)) > maxLineSize {
+				tooLong = true
+				r.line = r.line[:0]
+			}
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && (len(r.line) > 0 || tooLong):
+			// The last line has no line ending.
+			return trimLineEnding(r.line), tooLong, nil
+		case err != nil:
+			return nil, false, err
+		default:
+			return trimLineEnding(r.line), tooLong, nil
+		}
+	}
+}
+
+func trimLineEnding(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	return bytes.TrimSuffix(b, []byte("\r"))
+}
+
+func parseLine(b []byte) (Record, bool) {
+	var l journalLine
+	if err := json.Unmarshal(b, &l); err != nil {
+		return Record{}, false
+	}
+
+	switch {
+	case l.Entry != nil:
+		var ce ChainEntry
+		if err := json.Unmarshal(l.Entry, &ce); err != nil || ce.Record == nil {
+			return Record{}, false
+		}
+		return *ce.Record, true
+	case l.Record != nil:
+		var rec Record
+		if err := json.Unmarshal(l.Record, &rec); err != nil {
+			return Record{}, false
+		}
+		return rec, rec.Version > 0
+	case l.Version > 0:
+		var rec Record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return Record{}, false
+		}
+		return rec, true
+	default:
+		return Record{}, false
+	}
+}
+
+// Query selects audit records. The zero values of the fields match
+// everything.
+type Query struct {
+	// Only records at, or after, From.
+	From time.Time
+	// Only records before To.
+	To time.Time
+	// Only records whose SVID starts with SvidPrefix.
+	SvidPrefix string
+	// Only records of the given event.
+	Event Event
+	// Only records of the given correlation id.
+	CorrelationId string
+	// Number of matching records to skip.
+	Offset int
+	// Maximum number of records to return; 0 means no limit.
+	Limit int
+}
+
+// Matches returns true if rec satisfies the filters of the query. Offset and
+// Limit are not taken into account.
+func (q Query) Matches(rec Record) bool {
+	if !q.From.IsZero() && rec.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !rec.Timestamp.Before(q.To) {
+		return false
+	}
+	if q.SvidPrefix != "" && !strings.HasPrefix(rec.Svid, q.SvidPrefix) {
+		return false
+	}
+	if q.Event != "" && rec.Event != q.Event {
+		return false
+	}
+	if q.CorrelationId != "" && rec.CorrelationId != q.CorrelationId {
+		return false
+	}
+	return true
+}
+
+// Page is a page of the result of a Query.
+type Page struct {
+	// The matching records, in journal order.
+	Records []Record
+	// True if there are more matching records after this page.
+	More bool
+	// Offset of the next page; use it as the Offset of the next Query.
+	NextOffset int
+}
+
+// Search reads the journal that r provides, and returns the page of records
+// that q selects.
+func Search(r io.Reader, q Query) (Page, error) {
+	s := &search{query: q, page: Page{Records: []Record{}}}
+	if err := s.scan(r); err != nil {
+		return Page{}, err
+	}
+	return s.result(), nil
+}
+
+// SearchFile is like Search, but it reads the journal at path. Journals
+// that are gzip-compressed, such as the rotated files of a
+// RotatingFileSink, are decompressed.
+func SearchFile(path string, q Query) (Page, error) {
+	s := &search{query: q, page: Page{Records: []Record{}}}
+	if err := s.scanFile(path); err != nil {
+		return Page{}, err
+	}
+	return s.result(), nil
+}
+
+// SearchJournal is like SearchFile, but it reads the journal at path and
+// the files that a RotatingFileSink has rotated it into (see JournalFiles),
+// as a single journal, oldest record first.
+func SearchJournal(path string, q Query) (Page, error) {
+	files, err := JournalFiles(path)
+	if err != nil {
+		return Page{}, err
+	}
+
+	s := &search{query: q, page: Page{Records: []Record{}}}
+	for _, f := range files {
+		err := s.scanFile(f)
+		if os.IsNotExist(err) && f != path {
+			// Compressed since it was listed.
+			err = s.scanFile(f + ".gz")
+		}
+		if os.IsNotExist(err) {
+			// Removed since it was listed, or the journal itself
+			// has not been created yet.
+			continue
+		}
+		if err != nil {
+			return Page{}, err
+		}
+		if s.done {
+			break
+		}
+	}
+	return s.result(), nil
+}
+
+// JournalFiles returns the paths of the files that make up the journal at
+// path, oldest first: the files that a RotatingFileSink has rotated it into,
+// compressed or not, followed by path itself.
+func JournalFiles(path string) ([]string, error) {
+	rotated, err := rotatedJournals(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(rotated)+1)
+	for i := len(rotated) - 1; i >= 0; i-- {
+		files = append(files, rotated[i])
+	}
+	return append(files, path), nil
+}
+
+// search collects the page of records that query selects, from one or more
+// journals.
+type search struct {
+	query   Query
+	page    Page
+	skipped int
+	// True when the page is full.
+	done bool
+}
+
+func (s *search) scan(r io.Reader) error {
+	reader := NewReader(r)
+	for !s.done {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !s.query.Matches(rec) {
+			continue
+		}
+		if s.skipped < s.query.Offset {
+			s.skipped++
+			continue
+		}
+		if s.query.Limit > 0 && len(s.page.Records) == s.query.Limit {
+			s.page.More = true
+			s.done = true
+			return nil
+		}
+		s.page.Records = append(s.page.Records, rec)
+	}
+	return nil
+}
+
+func (s *search) scanFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		return s.scan(gz)
+	}
+	return s.scan(r)
+}
+
+func (s *search) result() Page {
+	s.page.NextOffset = s.query.Offset + len(s.page.Records)
+	return s.page
+}
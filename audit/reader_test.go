@@ -0,0 +1,249 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rotatedJournal writes count entries to a compressing RotatingFileSink,
+// rotating the journal after every perFile entries, and returns the path of
+// the journal.
+func rotatedJournal(t *testing.T, count, perFile int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "journal.log")
+	s, err := audit.NewRotatingFileSink(audit.RotatingFileConfig{
+		Path: path, Compress: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		if i > 0 && i%perFile == 0 {
+			if err := s.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Write(audit.JournalEntry{
+			CorrelationId: fmt.Sprintf("cid-%d", i),
+			Event:         audit.EventOk,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func correlationIds(p audit.Page) string {
+	ids := make([]string, len(p.Records))
+	for i, r := range p.Records {
+		ids[i] = r.CorrelationId
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSearchFileReadsCompressedJournals(t *testing.T) {
+	path := rotatedJournal(t, 4, 2)
+	files, err := audit.JournalFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || !strings.HasSuffix(files[0], ".log.gz") || files[1] != path {
+		t.Fatalf("got %v, want a compressed file, then the journal", files)
+	}
+
+	p, err := audit.SearchFile(files[0], audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1" {
+		t.Errorf("got %s", got)
+	}
+	p, err = audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-2,cid-3" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestSearchJournal(t *testing.T) {
+	path := rotatedJournal(t, 7, 2)
+
+	p, err := audit.SearchJournal(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := correlationIds(p),
+		"cid-0,cid-1,cid-2,cid-3,cid-4,cid-5,cid-6"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Pages span the files.
+	p, err = audit.SearchJournal(path, audit.Query{Offset: 1, Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-1,cid-2,cid-3" || !p.More ||
+		p.NextOffset != 4 {
+		t.Errorf("got %s, more: %v, next: %d", got, p.More, p.NextOffset)
+	}
+	p, err = audit.SearchJournal(path, audit.Query{Offset: 4, Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-4,cid-5,cid-6" || p.More {
+		t.Errorf("got %s, more: %v", got, p.More)
+	}
+
+	p, err = audit.SearchJournal(path, audit.Query{CorrelationId: "cid-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-5" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestSearchJournalWithoutRotatedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	p, err := audit.SearchJournal(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Records) != 0 {
+		t.Errorf("got %d records from a missing journal", len(p.Records))
+	}
+
+	if err := os.WriteFile(path, []byte(
+		`{"version":1,"correlationId":"only"}`+"\n",
+	), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err = audit.SearchJournal(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "only" {
+		t.Errorf("got %s", got)
+	}
+}
+
+// recordLines returns the journal lines of the given entries, as FileSink
+// writes them.
+func recordLines(t *testing.T, entries ...audit.JournalEntry) []string {
+	t.Helper()
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		b, err := json.Marshal(audit.NewRecord(e))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = string(b)
+	}
+	return lines
+}
+
+func TestSearchFilters(t *testing.T) {
+	start := time.Date(2023, 4, 1, 10, 0, 0, 0, time.UTC)
+	svid := "spiffe://aegis.ist/workload/"
+	journal := strings.Join(recordLines(t,
+		audit.JournalEntry{CorrelationId: "cid-0", Time: start,
+			Svid: svid + "a", Event: audit.EventOk},
+		audit.JournalEntry{CorrelationId: "cid-1", Time: start.Add(time.Minute),
+			Svid: svid + "b", Event: audit.EventBadSvid},
+		audit.JournalEntry{CorrelationId: "cid-2", Time: start.Add(2 * time.Minute),
+			Svid: svid + "ab", Event: audit.EventOk},
+		audit.JournalEntry{CorrelationId: "cid-3", Time: start.Add(3 * time.Minute),
+			Svid: "spiffe://other/workload/a", Event: audit.EventNoSecret},
+	), "\n")
+
+	tests := []struct {
+		name  string
+		query audit.Query
+		want  string
+	}{
+		{"everything", audit.Query{}, "cid-0,cid-1,cid-2,cid-3"},
+		{"from is inclusive", audit.Query{From: start.Add(time.Minute)}, "cid-1,cid-2,cid-3"},
+		{"to is exclusive", audit.Query{To: start.Add(2 * time.Minute)}, "cid-0,cid-1"},
+		{
+			"from and to",
+			audit.Query{From: start.Add(30 * time.Second), To: start.Add(150 * time.Second)},
+			"cid-1,cid-2",
+		},
+		{
+			"from in another time zone",
+			audit.Query{From: start.Add(2 * time.Minute).In(time.FixedZone("", -3600))},
+			"cid-2,cid-3",
+		},
+		{"svid prefix", audit.Query{SvidPrefix: svid + "a"}, "cid-0,cid-2"},
+		{"svid prefix of a trust domain", audit.Query{SvidPrefix: "spiffe://aegis.ist/"}, "cid-0,cid-1,cid-2"},
+		{"event", audit.Query{Event: audit.EventOk}, "cid-0,cid-2"},
+		{"event and svid prefix", audit.Query{Event: audit.EventOk, SvidPrefix: svid + "ab"}, "cid-2"},
+		{"no matches", audit.Query{Event: audit.EventAnomaly}, ""},
+		{
+			"filters before offset and limit",
+			audit.Query{SvidPrefix: "spiffe://aegis.ist/", Offset: 1, Limit: 1},
+			"cid-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := audit.Search(strings.NewReader(journal), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := correlationIds(p); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchSkipsOverLongLines(t *testing.T) {
+	lines := recordLines(t,
+		audit.JournalEntry{CorrelationId: "cid-0", Event: audit.EventOk},
+		audit.JournalEntry{CorrelationId: "cid-1", Event: audit.EventOk},
+		audit.JournalEntry{CorrelationId: "cid-2", Event: audit.EventOk},
+	)
+	long := strings.Repeat("x", 3*1024*1024)
+	journal := strings.Join([]string{
+		lines[0], long, lines[1] + "\r", `{"version":1,"correlationId":"` + long + `"}`,
+		lines[2],
+	}, "\n")
+
+	p, err := audit.Search(strings.NewReader(journal), audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1,cid-2" {
+		t.Errorf("got %s", got)
+	}
+
+	// An over-long last line, without a line ending, is skipped too.
+	p, err = audit.Search(strings.NewReader(lines[0]+"\n"+long), audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0" {
+		t.Errorf("got %s", got)
+	}
+}
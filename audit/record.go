@@ -0,0 +1,122 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RecordVersion is the version of the Record schema that this package emits.
+//
+// The version is incremented whenever a field is renamed, removed, or has
+// its meaning changed. Adding a new optional field does not change the
+// version; consumers are expected to ignore the fields they do not know.
+const RecordVersion = 1
+
+// Record is the serialized form of a JournalEntry. Each record is emitted
+// as a single JSON object, on a line of its own:
+//
+//	{
+//	  "version": 1,
+//	  "timestamp": "2023-04-01T10:20:30.123456789Z",
+//	  "correlationId": "cLz6OrYbKpGJ",
+//	  "method": "POST",
+//	  "url": "/workload/v1/secrets",
+//	  "svid": "spiffe://aegis.ist/workload/example/ns/default/sa/example/n/abc",
+//	  "event": "aegis-ok",
+//	  "error": "",
+//	  "created": "Sat Apr 01 10:20:30 +0000 2023",
+//	  "updated": "Sat Apr 01 10:20:30 +0000 2023"
+//	}
+//
+// `version` and `timestamp` are always present. `timestamp` is in RFC 3339
+// format with nanosecond precision, in UTC. The remaining string fields are
+// always present too, and they are empty when they do not apply to the
+// entity of the journal entry. `status` is present only when the response
+// has been served, and `fields` is present only when the entity reports
+// additional fields.
+type Record struct {
+	// Version of the record schema; see RecordVersion.
+	Version int `json:"version"`
+	// Time the journal entry was logged.
+	Timestamp time.Time `json:"timestamp"`
+	// Identifier that groups the records of a single request.
+	CorrelationId string `json:"correlationId"`
+	// HTTP method of the request.
+	Method string `json:"method"`
+	// URL of the request.
+	Url string `json:"url"`
+	// SPIFFE ID of the caller.
+	Svid string `json:"svid"`
+	// The audit event; one of the Event constants.
+	Event Event `json:"event"`
+	// HTTP status of the response; only present on EventExit records.
+	Status int `json:"status,omitempty"`
+	// Error reported by the entity, if any.
+	Error string `json:"error"`
+	// Creation time of the secret, if the entity carries one.
+	Created string `json:"created"`
+	// Last update time of the secret, if the entity carries one.
+	Updated string `json:"updated"`
+	// Any other field that the entity reports; see Auditable.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// NewRecord converts e into a Record.
+func NewRecord(e JournalEntry) Record {
+	r := Record{
+		Version:       RecordVersion,
+		Timestamp:     e.Time.UTC(),
+		CorrelationId: e.CorrelationId,
+		Method:        e.Method,
+		Url:           e.Url,
+		Svid:          e.Svid,
+		Event:         e.Event,
+		Status:        e.Status,
+	}
+
+	if e.Entity == nil {
+		return r
+	}
+
+	a, ok := e.Entity.(Auditable)
+	if !ok {
+		r.Fields = map[string]string{FieldEntity: fmt.Sprintf("%T", e.Entity)}
+		return r
+	}
+
+	for k, v := range a.AuditFields() {
+		switch k {
+		case FieldErr:
+			r.Error = v
+		case FieldCreated:
+			r.Created = v
+		case FieldUpdated:
+			r.Updated = v
+		default:
+			if r.Fields == nil {
+				r.Fields = map[string]string{}
+			}
+			r.Fields[k] = v
+		}
+	}
+
+	return r
+}
+
+// encode serializes e as a newline-terminated JSON Record.
+func encode(e JournalEntry) ([]byte, error) {
+	b, err := json.Marshal(NewRecord(e))
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,54 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"github.com/zerotohero-dev/aegis-core/audit"
+	notary "github.com/zerotohero-dev/aegis-core/entity/reqres/notary/v1"
+	reqres "github.com/zerotohero-dev/aegis-core/entity/reqres/safe/v1"
+	reqresv2 "github.com/zerotohero-dev/aegis-core/entity/reqres/safe/v2"
+	"testing"
+)
+
+func TestNewRecordMapsAuditFields(t *testing.T) {
+	r := audit.NewRecord(audit.JournalEntry{
+		Entity: reqres.SecretFetchResponse{
+			Err:     "failed",
+			Created: "yesterday",
+			Updated: "today",
+			Version: 3,
+		},
+	})
+	if r.Error != "failed" || r.Created != "yesterday" || r.Updated != "today" {
+		t.Errorf("got error %q, created %q, updated %q",
+			r.Error, r.Created, r.Updated)
+	}
+	for _, k := range []string{
+		audit.FieldErr, audit.FieldCreated, audit.FieldUpdated,
+	} {
+		if _, ok := r.Fields[k]; ok {
+			t.Errorf("%s is in the fields of the record: %v", k, r.Fields)
+		}
+	}
+	if r.Fields["version"] != "3" {
+		t.Errorf("version: got %q, want 3", r.Fields["version"])
+	}
+
+	for _, entity := range []audit.Auditable{
+		reqres.SecretUpsertRequest{Err: "failed"},
+		reqres.SecretListResponse{Err: "failed"},
+		reqresv2.SecretListResponse{Err: "failed"},
+		notary.RegisterWorkloadResponse{Err: "failed"},
+	} {
+		r := audit.NewRecord(audit.JournalEntry{Entity: entity})
+		if r.Error != "failed" {
+			t.Errorf("%T: got error %q, want %q", entity, r.Error, "failed")
+		}
+	}
+}
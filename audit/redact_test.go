@@ -0,0 +1,118 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	reqres "github.com/zerotohero-dev/aegis-core/entity/reqres/safe/v1"
+	"strings"
+	"testing"
+)
+
+const secret = "hunter2"
+
+type opaque struct {
+	Name  string
+	inner reqres.SecretUpsertRequest
+	Items []any
+}
+
+// expectNoSecret fails if the secret appears in any form of the entries that
+// the sink has received: printed, JSON, or as an audit Record.
+func expectNoSecret(t *testing.T, sink *audit.MemorySink) {
+	t.Helper()
+	entries := sink.Entries()
+	if len(entries) == 0 {
+		t.Fatal("the sink has received no entries")
+	}
+	for _, e := range entries {
+		forms := []string{fmt.Sprintf("%+v", e), fmt.Sprintf("%#v", e)}
+		if b, err := json.Marshal(e); err == nil {
+			forms = append(forms, string(b))
+		}
+		b, err := json.Marshal(audit.NewRecord(e))
+		if err != nil {
+			t.Fatalf("json.Marshal: %s", err.Error())
+		}
+		forms = append(forms, string(b))
+		for _, f := range forms {
+			if strings.Contains(f, secret) {
+				t.Errorf("the sink has received the secret: %s", f)
+			}
+		}
+	}
+}
+
+func TestLogRedactsEntities(t *testing.T) {
+	upsert := reqres.SecretUpsertRequest{
+		WorkloadId: "example",
+		Value:      secret,
+		Template:   secret,
+		Values:     map[string]string{"password": secret},
+	}
+	stored := data.SecretStored{
+		Name:             "example",
+		Value:            secret,
+		ValueTransformed: secret,
+		Values:           map[string]string{"password": secret},
+		Meta:             data.SecretMeta{Template: secret},
+		History: []data.SecretVersion{
+			{Version: 1, Value: secret, ValueTransformed: secret},
+		},
+	}
+
+	entities := []any{
+		upsert,
+		&upsert,
+		reqres.SecretFetchResponse{Data: secret},
+		stored,
+		&stored,
+		opaque{Name: "opaque", inner: upsert, Items: []any{upsert, &stored}},
+		map[string]any{"request": upsert},
+	}
+
+	for i, entity := range entities {
+		t.Run(fmt.Sprintf("%T", entity), func(t *testing.T) {
+			sink := audit.NewMemorySink()
+			name := fmt.Sprintf("redact-test-%d", i)
+			audit.RegisterSink(name, sink)
+			defer func() { _ = audit.UnregisterSink(name) }()
+
+			audit.Log(audit.JournalEntry{
+				CorrelationId: "cid",
+				Entity:        entity,
+				Event:         audit.EventOk,
+			})
+			expectNoSecret(t, sink)
+		})
+	}
+
+	if upsert.Value != secret || stored.History[0].Value != secret {
+		t.Error("Log has modified the entity")
+	}
+}
+
+func TestLogKeepsNonSecretFields(t *testing.T) {
+	sink := audit.NewMemorySink()
+	audit.RegisterSink("redact-test-fields", sink)
+	defer func() { _ = audit.UnregisterSink("redact-test-fields") }()
+
+	audit.Log(audit.JournalEntry{
+		Entity: reqres.SecretUpsertRequest{WorkloadId: "example", Value: secret},
+		Event:  audit.EventOk,
+	})
+
+	r := audit.NewRecord(sink.Entries()[0])
+	if r.Fields["workloadId"] != "example" {
+		t.Errorf("workloadId: got %q, want %q", r.Fields["workloadId"], "example")
+	}
+}
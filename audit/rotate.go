@@ -0,0 +1,369 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"compress/gzip"
+	"github.com/zerotohero-dev/aegis-core/env"
+	"github.com/zerotohero-dev/aegis-core/log"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the time stamp in the names of the rotated files.
+// It sorts lexically in chronological order.
+const rotatedTimeFormat = "20060102T150405.000000000Z"
+
+// RotatingFileConfig configures a RotatingFileSink.
+type RotatingFileConfig struct {
+	// Path of the journal file. The rotated files are kept in the same
+	// directory, named after the journal file, such as
+	// `journal-20230401T102030.000000000Z.log` for `journal.log`.
+	Path string
+	// Size, in bytes, that the journal file can grow to before it is
+	// rotated; 0 disables size-based rotation.
+	MaxSize int64
+	// How long the journal file is written to before it is rotated; 0
+	// disables time-based rotation.
+	RotateEvery time.Duration
+	// How long the rotated files are retained; 0 retains them forever.
+	MaxAge time.Duration
+	// Number of rotated files to retain; 0 retains all of them.
+	MaxFiles int
+	// If true, the rotated files are gzip-compressed.
+	Compress bool
+}
+
+// RotatingFileConfigFromEnv returns the rotating file configuration that the
+// environment specifies; see env.AuditJournalPath and the other
+// env.AuditJournal functions.
+func RotatingFileConfigFromEnv() RotatingFileConfig {
+	return RotatingFileConfig{
+		Path:        env.AuditJournalPath(),
+		MaxSize:     env.AuditJournalMaxSize(),
+		RotateEvery: env.AuditJournalRotateInterval(),
+		MaxAge:      env.AuditJournalMaxAge(),
+		MaxFiles:    env.AuditJournalMaxFiles(),
+		Compress:    env.AuditJournalCompress(),
+	}
+}
+
+// RotatingFileSink appends audit journal entries to a file, as one JSON
+// Record per line, and rotates the file when it gets too large or too old.
+//
+// Every record is written with a single write call, and a record never spans
+// two files. Rotated files are compressed into a temporary file that is
+// renamed into place only when complete, so a crash never leaves a partial
+// archive behind. Compression and retention run in the background, off the
+// write path. RotatingFileSink is safe for concurrent use.
+type RotatingFileSink struct {
+	mux    sync.Mutex
+	config RotatingFileConfig
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	millCh chan struct{}
+	millWg sync.WaitGroup
+}
+
+// NewRotatingFileSink opens (or creates) the journal file, creating its
+// directory if needed, and returns a Sink that writes to it.
+func NewRotatingFileSink(c RotatingFileConfig) (*RotatingFileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return nil, err
+	}
+
+	s := &RotatingFileSink{
+		config: c,
+		millCh: make(chan struct{}, 1),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	s.millWg.Add(1)
+	go func() {
+		defer s.millWg.Done()
+		for range s.millCh {
+			s.mill()
+		}
+	}()
+	// Apply the retention policy to what the previous runs left behind.
+	s.millCh <- struct{}{}
+
+	return s, nil
+}
+
+func (s *RotatingFileSink) Write(e JournalEntry) error {
+	b, err := encode(e)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if s.shouldRotate(int64(len(b))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the journal file, and waits for the pending compression and
+// retention work to finish.
+func (s *RotatingFileSink) Close() error {
+	s.mux.Lock()
+	f := s.file
+	s.file = nil
+	if f != nil {
+		close(s.millCh)
+	}
+	s.mux.Unlock()
+
+	if f == nil {
+		return nil
+	}
+
+	var err error
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+	} else {
+		err = f.Close()
+	}
+	s.millWg.Wait()
+	return err
+}
+
+// Rotate rotates the journal file immediately.
+func (s *RotatingFileSink) Rotate() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	return s.rotate()
+}
+
+func (s *RotatingFileSink) shouldRotate(n int64) bool {
+	if s.size == 0 {
+		// Never rotate an empty file; a record that is larger than
+		// MaxSize gets a file of its own.
+		return false
+	}
+	if s.config.MaxSize > 0 && s.size+n > s.config.MaxSize {
+		return true
+	}
+	if s.config.RotateEvery > 0 && time.Since(s.opened) >= s.config.RotateEvery {
+		return true
+	}
+	return false
+}
+
+// open opens the journal file. Must be called with s.mux held.
+func (s *RotatingFileSink) open() error {
+	f, err := os.OpenFile(
+		s.config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600,
+	)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	s.file = f
+	s.size = info.Size()
+	s.opened = time.Now()
+	return nil
+}
+
+// rotate moves the journal file aside, and opens a new one. Must be called
+// with s.mux held.
+func (s *RotatingFileSink) rotate() error {
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	base, ext := s.nameParts()
+	rotated := base + "-" + time.Now().UTC().Format(rotatedTimeFormat) + ext
+	if err := os.Rename(s.config.Path, rotated); err != nil {
+		// Keep writing to the same file rather than losing records.
+		if oerr := s.open(); oerr != nil {
+			s.file = nil
+			return oerr
+		}
+		return err
+	}
+	if err := s.open(); err != nil {
+		s.file = nil
+		return err
+	}
+
+	select {
+	case s.millCh <- struct{}{}:
+	default:
+		// The mill is already scheduled to run.
+	}
+	return nil
+}
+
+// nameParts splits the journal path into its extension, and the rest.
+func (s *RotatingFileSink) nameParts() (string, string) {
+	return journalNameParts(s.config.Path)
+}
+
+func journalNameParts(path string) (string, string) {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext), ext
+}
+
+// rotatedFiles returns the paths of the rotated files, newest first.
+func (s *RotatingFileSink) rotatedFiles() ([]string, error) {
+	return rotatedJournals(s.config.Path)
+}
+
+// rotatedJournals returns the paths of the rotated files of the journal at
+// path, compressed or not, newest first. Only the names that a rotation
+// produces are matched: for `journal.log`, `journal-<time stamp>.log` and
+// `journal-<time stamp>.log.gz`, where the time stamp is in
+// rotatedTimeFormat. Other files in the directory, such as
+// `journal-chain.log`, are left alone.
+func rotatedJournals(path string) ([]string, error) {
+	base, ext := journalNameParts(path)
+	dir, prefix := filepath.Dir(base), filepath.Base(base)+"-"
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok {
+			continue
+		}
+		stamp = strings.TrimSuffix(stamp, ".gz")
+		if stamp, ok = strings.CutSuffix(stamp, ext); !ok {
+			continue
+		}
+		if _, err := time.Parse(rotatedTimeFormat, stamp); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// mill compresses the rotated files, and removes the ones that the retention
+// policy no longer keeps.
+func (s *RotatingFileSink) mill() {
+	files, err := s.rotatedFiles()
+	if err != nil {
+		log.ErrorLn("audit: failed to list rotated journals", err.Error())
+		return
+	}
+
+	now := time.Now()
+	for i, f := range files {
+		expired := s.config.MaxFiles > 0 && i >= s.config.MaxFiles
+		if !expired && s.config.MaxAge > 0 {
+			if info, err := os.Stat(f); err == nil {
+				expired = now.Sub(info.ModTime()) > s.config.MaxAge
+			}
+		}
+
+		if expired {
+			if err := os.Remove(f); err != nil {
+				log.ErrorLn("audit: failed to remove", f, err.Error())
+			}
+			continue
+		}
+
+		if s.config.Compress && !strings.HasSuffix(f, ".gz") {
+			if err := compress(f); err != nil {
+				log.ErrorLn("audit: failed to compress", f, err.Error())
+			}
+		}
+	}
+}
+
+// compress gzips the file at path into path.gz, and removes path. The
+// archive is written to a temporary file first, and renamed into place only
+// when it is complete.
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Keep the age of the archive for the retention policy.
+		err = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
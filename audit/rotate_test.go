@@ -0,0 +1,306 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRotatingSink(t *testing.T, c audit.RotatingFileConfig) *audit.RotatingFileSink {
+	t.Helper()
+	s, err := audit.NewRotatingFileSink(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func writeEntries(t *testing.T, s audit.Sink, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := s.Write(audit.JournalEntry{
+			CorrelationId: fmt.Sprintf("cid-%d", i),
+			Event:         audit.EventOk,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// dirNames returns the names of the files in dir, sorted.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func rotatedName(name string) bool {
+	return strings.HasPrefix(name, "journal-2") &&
+		(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz"))
+}
+
+// rotatedFile returns the path of a rotated file that was rotated at t.
+func rotatedFile(dir string, t time.Time) string {
+	return filepath.Join(
+		dir, "journal-"+t.UTC().Format("20060102T150405.000000000Z")+".log",
+	)
+}
+
+func TestRotatingFileSinkSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journal.log")
+	// Every entry is about 100 bytes; rotate after a few of them.
+	s := newRotatingSink(t, audit.RotatingFileConfig{Path: path, MaxSize: 300})
+	writeEntries(t, s, 0, 10)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := audit.JournalFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 3 {
+		t.Fatalf("got %v, want the journal to be rotated", files)
+	}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s has %d bytes, more than MaxSize", f, info.Size())
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %v, want 0600", f, info.Mode().Perm())
+		}
+	}
+
+	// No record is lost, or split across files.
+	p, err := audit.SearchJournal(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Records) != 10 || p.Records[9].CorrelationId != "cid-9" {
+		t.Errorf("got %d records", len(p.Records))
+	}
+}
+
+func TestRotatingFileSinkTimeRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journal.log")
+	s := newRotatingSink(t, audit.RotatingFileConfig{
+		Path: path, RotateEvery: 20 * time.Millisecond,
+	})
+	writeEntries(t, s, 0, 2)
+	time.Sleep(30 * time.Millisecond)
+	writeEntries(t, s, 2, 3)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := audit.JournalFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %v, want one rotated file and the journal", files)
+	}
+	p, err := audit.SearchFile(files[0], audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1" {
+		t.Errorf("rotated file: got %s", got)
+	}
+	p, err = audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-2" {
+		t.Errorf("journal: got %s", got)
+	}
+}
+
+func TestRotatingFileSinkCompression(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journal.log")
+	s := newRotatingSink(t, audit.RotatingFileConfig{Path: path, Compress: true})
+	writeEntries(t, s, 0, 2)
+	if err := s.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	writeEntries(t, s, 2, 3)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive string
+	for _, n := range dirNames(t, dir) {
+		if strings.HasSuffix(n, ".tmp") {
+			t.Errorf("a temporary file is left behind: %s", n)
+		}
+		if rotatedName(n) {
+			if !strings.HasSuffix(n, ".gz") {
+				t.Errorf("%s is not compressed", n)
+			}
+			archive = filepath.Join(dir, n)
+		}
+	}
+	if archive == "" {
+		t.Fatal("there is no rotated file")
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("the archive is not gzip-compressed: %s", err.Error())
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "cid-1") || strings.Contains(string(b), "cid-2") {
+		t.Errorf("got %s", b)
+	}
+}
+
+func TestRotatingFileSinkMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journal.log")
+	s := newRotatingSink(t, audit.RotatingFileConfig{Path: path, MaxFiles: 2})
+	for i := 0; i < 5; i++ {
+		writeEntries(t, s, i, i+1)
+		if err := s.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeEntries(t, s, 5, 6)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The newest rotated files are kept.
+	p, err := audit.SearchJournal(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-3,cid-4,cid-5" {
+		t.Errorf("got %s, want the two newest rotated files and the journal", got)
+	}
+}
+
+func TestRotatingFileSinkMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journal.log")
+
+	now := time.Now()
+	old := rotatedFile(dir, now.Add(-2*time.Hour))
+	recent := rotatedFile(dir, now.Add(-time.Minute))
+	for _, f := range []string{old, recent} {
+		if err := os.WriteFile(f, []byte("{}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(old, now.Add(-2*time.Hour), now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retention policy is applied to the files of previous runs.
+	s := newRotatingSink(t, audit.RotatingFileConfig{Path: path, MaxAge: time.Hour})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("the expired file is kept: %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("the recent file is removed: %s", err.Error())
+	}
+}
+
+// TestRotatingFileSinkLeavesOtherFilesAlone checks that retention and
+// compression only touch the files that rotation has produced, even when
+// another journal with a similar name, such as a hash chain, shares the
+// directory.
+func TestRotatingFileSinkLeavesOtherFilesAlone(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journal.log")
+	others := []string{
+		"journal-chain.log",
+		"journal-zzz.log.gz",
+		"journal-20230401T102030.log",
+		"journal-20230401T102030.000000000Z.txt",
+	}
+	for _, n := range others {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte("x\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newRotatingSink(t, audit.RotatingFileConfig{
+		Path: path, Compress: true, MaxFiles: 1,
+	})
+	for i := 0; i < 2; i++ {
+		writeEntries(t, s, i, i+1)
+		if err := s.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := dirNames(t, dir)
+	for _, n := range others {
+		b, err := os.ReadFile(filepath.Join(dir, n))
+		if err != nil || string(b) != "x\n" {
+			t.Errorf("%s is changed or removed: %v", n, names)
+		}
+	}
+	var rotated []string
+	for _, n := range names {
+		if rotatedName(n) && strings.HasSuffix(n, "Z.log.gz") {
+			rotated = append(rotated, n)
+		}
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("got the rotated files %v, want one", rotated)
+	}
+
+	files, err := audit.JournalFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != rotated[0] {
+		t.Errorf("got the journal files %v", files)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// KeyId returns the identifier of an ed25519 public key: the first eight
+// bytes of its SHA-256 hash, hex-encoded.
+func KeyId(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Signer signs audit records with an ed25519 private key.
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyId string
+}
+
+// NewSigner creates a Signer that signs with key.
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		key:   key,
+		keyId: KeyId(key.Public().(ed25519.PublicKey)),
+	}
+}
+
+// LoadSigner creates a Signer from the PEM-encoded PKCS #8 ed25519 private
+// key at path; see env.SafeAuditSigningKeyPath.
+func LoadSigner(path string) (*Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("audit: no PEM data in the signing key file")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("audit: the signing key is not an ed25519 key")
+	}
+	return NewSigner(key), nil
+}
+
+// KeyId returns the identifier of the public key of the signer.
+func (s *Signer) KeyId() string {
+	return s.keyId
+}
+
+// PublicKey returns the public key of the signer.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign returns the base64-encoded signature of payload.
+func (s *Signer) Sign(payload []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, payload))
+}
+
+// KeyRing maps key ids to the public keys to verify signatures with.
+//
+// Every signature carries the id of the key that produced it (see KeyId), so
+// a KeyRing can hold the current key and any number of retired keys. To
+// rotate the signing key:
+//
+//  1. Generate a new ed25519 key, and replace the file at
+//     env.SafeAuditSigningKeyPath() with it.
+//  2. Copy the public key of the new key, in PKIX, PEM-encoded format, to the
+//     directory at env.SafeAuditVerificationKeysPath(). Leave the public keys
+//     of the older signing keys in that directory.
+//  3. Restart Aegis Safe.
+//
+// The records that the older keys signed remain verifiable for as long as
+// their public keys are kept in the directory.
+type KeyRing map[string]ed25519.PublicKey
+
+// Add adds pub to the key ring.
+func (k KeyRing) Add(pub ed25519.PublicKey) {
+	k[KeyId(pub)] = pub
+}
+
+// Verify checks that signature is a valid signature of payload by the key
+// with the given id.
+func (k KeyRing) Verify(keyId string, payload []byte, signature string) error {
+	pub, ok := k[keyId]
+	if !ok {
+		return fmt.Errorf("audit: unknown key id %q", keyId)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("audit: malformed signature: %s", err.Error())
+	}
+	if !ed25519.Verify(pub, payload, sig) {
+		return errors.New("audit: signature does not verify")
+	}
+	return nil
+}
+
+// LoadKeyRing reads every `*.pem` file in dir as a PKIX, PEM-encoded ed25519
+// public key; see env.SafeAuditVerificationKeysPath.
+func LoadKeyRing(dir string) (KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := KeyRing{}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, fmt.Errorf("audit: no PEM data in %s", path)
+		}
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("audit: %s: %s", path, err.Error())
+		}
+		pub, ok := k.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("audit: %s is not an ed25519 key", path)
+		}
+		keys.Add(pub)
+	}
+	return keys, nil
+}
+
+// SignedRecord is a Record together with its signature. The signature is
+// computed over the exact bytes of Record.
+type SignedRecord struct {
+	Record    json.RawMessage `json:"record"`
+	KeyId     string          `json:"keyId"`
+	Signature string          `json:"sig"`
+}
+
+// SignedSink appends audit journal entries to a file, as one SignedRecord
+// per line. Signed records can be exported off-cluster and verified with
+// VerifySigned, provided that the public key of the signer is known.
+type SignedSink struct {
+	mux    sync.Mutex
+	file   *os.File
+	signer *Signer
+}
+
+// NewSignedSink opens (or creates) the file at path for appending, and returns
+// a Sink that signs every record with signer before writing it.
+func NewSignedSink(path string, signer *Signer) (*SignedSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedSink{file: f, signer: signer}, nil
+}
+
+func (s *SignedSink) Write(e JournalEntry) error {
+	r, err := json.Marshal(NewRecord(e))
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(SignedRecord{
+		Record:    r,
+		KeyId:     s.signer.KeyId(),
+		Signature: s.signer.Sign(r),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, err = s.file.Write(append(b, '\n'))
+	return err
+}
+
+func (s *SignedSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.file.Sync(); err != nil {
+		_ = s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// BadSignatureError describes the first record of a signed journal that
+// fails verification.
+type BadSignatureError struct {
+	// Line number in the journal, starting from 1.
+	Line   int
+	KeyId  string
+	Reason string
+}
+
+func (e *BadSignatureError) Error() string {
+	return fmt.Sprintf(
+		"audit: bad signature at line %d (key %q): %s",
+		e.Line, e.KeyId, strings.TrimPrefix(e.Reason, "audit: "),
+	)
+}
+
+// VerifySigned checks the signature of every SignedRecord that r provides.
+// It returns a *BadSignatureError for the first record that does not verify,
+// nil if all records verify, and any other error if r cannot be read.
+func VerifySigned(r io.Reader, keys KeyRing) error {
+	line := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var sr SignedRecord
+		if err := json.Unmarshal(scanner.Bytes(), &sr); err != nil {
+			return &BadSignatureError{Line: line,
+				Reason: "malformed record: " + err.Error()}
+		}
+		if err := keys.Verify(sr.KeyId, sr.Record, sr.Signature); err != nil {
+			return &BadSignatureError{Line: line, KeyId: sr.KeyId,
+				Reason: err.Error()}
+		}
+	}
+
+	return scanner.Err()
+}
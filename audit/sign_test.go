@@ -0,0 +1,351 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writePem(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	b := pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writePrivateKey writes key to path in the format that LoadSigner reads.
+func writePrivateKey(t *testing.T, path string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePem(t, path, "PRIVATE KEY", der)
+}
+
+// writePublicKey writes pub to path in the format that LoadKeyRing reads.
+func writePublicKey(t *testing.T, path string, pub any) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePem(t, path, "PUBLIC KEY", der)
+}
+
+func writeSigned(t *testing.T, path string, signer *audit.Signer, from, to int) {
+	t.Helper()
+	s, err := audit.NewSignedSink(path, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEntries(t, s, from, to)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verifySignedFile(t *testing.T, path string, keys audit.KeyRing) error {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return audit.VerifySigned(f, keys)
+}
+
+func verifyChainFileWithKeys(t *testing.T, path string, keys audit.KeyRing) error {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return audit.VerifyChainWithKeys(f, keys)
+}
+
+func badSignature(t *testing.T, err error) *audit.BadSignatureError {
+	t.Helper()
+	var bad *audit.BadSignatureError
+	if !errors.As(err, &bad) {
+		t.Fatalf("want a *BadSignatureError, got %v", err)
+	}
+	return bad
+}
+
+func TestLoadSigner(t *testing.T) {
+	key := newKey(t)
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	writePrivateKey(t, path, key)
+
+	signer, err := audit.LoadSigner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	if signer.KeyId() != audit.KeyId(pub) || !signer.PublicKey().Equal(pub) {
+		t.Errorf("want the key %s, got %s", audit.KeyId(pub), signer.KeyId())
+	}
+
+	keys := audit.KeyRing{}
+	keys.Add(pub)
+	payload := []byte("payload")
+	if err := keys.Verify(signer.KeyId(), payload, signer.Sign(payload)); err != nil {
+		t.Error(err)
+	}
+	if err := keys.Verify(signer.KeyId(), []byte("other"), signer.Sign(payload)); err == nil {
+		t.Error("want the signature of another payload to be rejected")
+	}
+}
+
+func TestLoadSignerRejectsInvalidKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		write func(t *testing.T, path string)
+	}{
+		{
+			name: "not an ed25519 key",
+			write: func(t *testing.T, path string) {
+				writePrivateKey(t, path, ecKey)
+			},
+		},
+		{
+			name: "not PEM",
+			write: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("key"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "not PKCS #8",
+			write: func(t *testing.T, path string) {
+				writePem(t, path, "PRIVATE KEY", []byte("key"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "signing.pem")
+			tt.write(t, path)
+			if _, err := audit.LoadSigner(path); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	old, current := newKey(t), newKey(t)
+	writePublicKey(t, filepath.Join(dir, "old.pem"), old.Public())
+	writePublicKey(t, filepath.Join(dir, "current.pem"), current.Public())
+	// Files without the .pem extension are not keys.
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("keys"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := audit.LoadKeyRing(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("want 2 keys, got %d", len(keys))
+	}
+	for _, key := range []ed25519.PrivateKey{old, current} {
+		pub := key.Public().(ed25519.PublicKey)
+		if !keys[audit.KeyId(pub)].Equal(pub) {
+			t.Errorf("key %s is missing", audit.KeyId(pub))
+		}
+	}
+}
+
+func TestLoadKeyRingRejectsNonEd25519Keys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writePublicKey(t, filepath.Join(dir, "current.pem"), newKey(t).Public())
+	writePublicKey(t, filepath.Join(dir, "ec.pem"), ecKey.Public())
+
+	_, err = audit.LoadKeyRing(dir)
+	if err == nil || !strings.Contains(err.Error(), "ec.pem is not an ed25519 key") {
+		t.Errorf("want the ecdsa key to be rejected, got %v", err)
+	}
+}
+
+func TestSignedSinkKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signed.log")
+	old, current := audit.NewSigner(newKey(t)), audit.NewSigner(newKey(t))
+
+	// Records signed before, and after, the rotation share the journal.
+	writeSigned(t, path, old, 0, 2)
+	writeSigned(t, path, current, 2, 3)
+
+	keys := audit.KeyRing{}
+	keys.Add(old.PublicKey())
+	keys.Add(current.PublicKey())
+	if err := verifySignedFile(t, path, keys); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1,cid-2" {
+		t.Errorf("got records %s", got)
+	}
+
+	// Without the retired key, the older records cannot be verified.
+	keys = audit.KeyRing{}
+	keys.Add(current.PublicKey())
+	bad := badSignature(t, verifySignedFile(t, path, keys))
+	if bad.Line != 1 || bad.KeyId != old.KeyId() ||
+		!strings.Contains(bad.Reason, "unknown key id") {
+		t.Errorf("want an unknown key at line 1, got %v", bad)
+	}
+}
+
+func TestVerifySignedDetectsTampering(t *testing.T) {
+	signer := audit.NewSigner(newKey(t))
+	keys := audit.KeyRing{}
+	keys.Add(signer.PublicKey())
+
+	tests := []struct {
+		name   string
+		tamper func(line []byte) []byte
+	}{
+		{
+			name: "edited record",
+			tamper: func(line []byte) []byte {
+				return bytes.Replace(line, []byte("cid-1"), []byte("cid-9"), 1)
+			},
+		},
+		{
+			name: "signature of another key",
+			tamper: func(line []byte) []byte {
+				other := audit.NewSigner(newKey(t)).KeyId()
+				return bytes.Replace(line, []byte(signer.KeyId()), []byte(other), 1)
+			},
+		},
+		{
+			name: "malformed signature",
+			tamper: func(line []byte) []byte {
+				return bytes.Replace(line, []byte(`"sig":"`), []byte(`"sig":"!`), 1)
+			},
+		},
+		{
+			name: "malformed record",
+			tamper: func(line []byte) []byte {
+				return line[:len(line)/2]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "signed.log")
+			writeSigned(t, path, signer, 0, 3)
+			lines := chainLines(t, path)
+			lines[1] = tt.tamper(lines[1])
+			writeChainLines(t, path, lines)
+
+			bad := badSignature(t, verifySignedFile(t, path, keys))
+			if bad.Line != 2 {
+				t.Errorf("want line 2, got %v", bad)
+			}
+		})
+	}
+}
+
+func TestVerifyChainWithKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.log")
+	old, current := audit.NewSigner(newKey(t)), audit.NewSigner(newKey(t))
+
+	// Lines 1-2 are records, 3 is a checkpoint signed by the old key, 4 is a
+	// record, and 5 is a checkpoint signed by the current key.
+	for i, signer := range []*audit.Signer{old, current} {
+		s, err := audit.NewChainSink(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SignCheckpoints(signer)
+		writeEntries(t, s, 2*i, 2+i)
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(chainLines(t, path)); n != 5 {
+		t.Fatalf("want 5 links, got %d", n)
+	}
+
+	keys := audit.KeyRing{}
+	keys.Add(old.PublicKey())
+	keys.Add(current.PublicKey())
+	if err := verifyChainFileWithKeys(t, path, keys); err != nil {
+		t.Fatal(err)
+	}
+
+	// After the rotation, checkpoints of the retired key need its public
+	// key to verify.
+	keys = audit.KeyRing{}
+	keys.Add(current.PublicKey())
+	err := verifyChainFileWithKeys(t, path, keys)
+	if line := brokenLine(t, err); line != 3 ||
+		!strings.Contains(err.Error(), "unknown key id") {
+		t.Errorf("want an unknown key at line 3, got %v", err)
+	}
+
+	// A forged signature does not verify, even with a correct hash.
+	keys.Add(old.PublicKey())
+	lines := chainLines(t, path)
+	ce := chainEntry(t, lines[4])
+	ce.Checkpoint.Signature = current.Sign([]byte("forged"))
+	lines[4] = relink(t, ce)
+	writeChainLines(t, path, lines)
+	err = verifyChainFileWithKeys(t, path, keys)
+	if line := brokenLine(t, err); line != 5 {
+		t.Errorf("want line 5, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"github.com/zerotohero-dev/aegis-core/log"
+	"sort"
+	"sync"
+)
+
+// Sink is a destination for audit journal entries.
+//
+// Implementations must be safe for concurrent use, as Log can be called
+// from several goroutines at once.
+type Sink interface {
+	// Write delivers a single journal entry to the sink.
+	Write(e JournalEntry) error
+	// Close flushes any pending data and releases the resources that
+	// the sink holds. A closed sink shall not be written to again.
+	Close() error
+}
+
+// SinkNameStdout is the name of the Sink that is registered by default.
+const SinkNameStdout = "stdout"
+
+var sinks = map[string]Sink{
+	SinkNameStdout: NewStdoutSink(),
+}
+var sinksMux sync.RWMutex
+
+// RegisterSink adds s to the set of sinks that receive audit journal entries,
+// under the given name. If there is already a sink with the same name, it
+// will be replaced; the replaced sink is not closed.
+func RegisterSink(name string, s Sink) {
+	sinksMux.Lock()
+	defer sinksMux.Unlock()
+	sinks[name] = s
+}
+
+// UnregisterSink removes the sink with the given name from the registry, and
+// closes it. It is a no-op if there is no such sink.
+func UnregisterSink(name string) error {
+	sinksMux.Lock()
+	s, ok := sinks[name]
+	delete(sinks, name)
+	sinksMux.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return s.Close()
+}
+
+// RegisteredSinks returns the names of the registered sinks, sorted.
+func RegisteredSinks() []string {
+	sinksMux.RLock()
+	defer sinksMux.RUnlock()
+
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dispatch writes e to every registered sink. A failing sink does not
+// prevent the remaining sinks from receiving the entry.
+func dispatch(e JournalEntry) {
+	sinksMux.RLock()
+	defer sinksMux.RUnlock()
+
+	for name, s := range sinks {
+		if err := s.Write(e); err != nil {
+			failed.Add(1)
+			log.ErrorLn(
+				log.CorrelationId(e.CorrelationId),
+				"audit: failed to write to sink", name, err.Error(),
+			)
+			continue
+		}
+		delivered.Add(1)
+	}
+}
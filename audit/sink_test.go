@@ -0,0 +1,156 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"github.com/zerotohero-dev/aegis-core/log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// closingSink is a MemorySink that remembers whether it has been closed.
+type closingSink struct {
+	*audit.MemorySink
+	closed bool
+}
+
+func (s *closingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// failingSink fails every write.
+type failingSink struct{}
+
+func (failingSink) Write(e audit.JournalEntry) error {
+	return errors.New("disk full")
+}
+
+func (failingSink) Close() error {
+	return nil
+}
+
+// logRecorder is a log.Backend that keeps the lines it is given.
+type logRecorder struct {
+	mux   sync.Mutex
+	lines []string
+}
+
+func (r *logRecorder) Log(l log.Level, v ...any) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.lines = append(r.lines, fmt.Sprintln(v...))
+}
+
+func (r *logRecorder) text() string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return strings.Join(r.lines, "")
+}
+
+func hasSink(name string) bool {
+	for _, n := range audit.RegisteredSinks() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRegisterSink(t *testing.T) {
+	first := &closingSink{MemorySink: audit.NewMemorySink()}
+	withSink(t, "sink-test-register", first)
+	if !hasSink("sink-test-register") || hasSink(audit.SinkNameStdout) {
+		t.Fatalf("got sinks %v", audit.RegisteredSinks())
+	}
+
+	audit.Log(audit.JournalEntry{CorrelationId: "cid-0", Event: audit.EventOk})
+
+	// Registering under the same name replaces the sink, without closing
+	// it.
+	second := &closingSink{MemorySink: audit.NewMemorySink()}
+	audit.RegisterSink("sink-test-register", second)
+	audit.Log(audit.JournalEntry{CorrelationId: "cid-1", Event: audit.EventOk})
+
+	if first.closed {
+		t.Error("want the replaced sink not to be closed")
+	}
+	if n := len(first.Entries()); n != 1 {
+		t.Errorf("want the replaced sink to get 1 entry, got %d", n)
+	}
+	if e := second.Entries(); len(e) != 1 || e[0].CorrelationId != "cid-1" {
+		t.Errorf("got entries %+v", e)
+	}
+
+	if err := audit.UnregisterSink("sink-test-register"); err != nil {
+		t.Fatal(err)
+	}
+	if !second.closed || hasSink("sink-test-register") {
+		t.Error("want the unregistered sink to be closed and removed")
+	}
+	audit.Log(audit.JournalEntry{CorrelationId: "cid-2", Event: audit.EventOk})
+	if n := len(second.Entries()); n != 1 {
+		t.Errorf("want the unregistered sink to get no entries, got %d", n)
+	}
+
+	if err := audit.UnregisterSink("sink-test-missing"); err != nil {
+		t.Errorf("want unregistering a missing sink to be a no-op, got %v", err)
+	}
+}
+
+func TestFailingSinkDoesNotBlockTheOthers(t *testing.T) {
+	logs := &logRecorder{}
+	log.SetBackend(logs)
+	t.Cleanup(func() { log.SetBackend(nil) })
+
+	sink := audit.NewMemorySink()
+	withSink(t, "sink-test-ok", sink)
+	audit.RegisterSink("sink-test-failing", failingSink{})
+	t.Cleanup(func() { _ = audit.UnregisterSink("sink-test-failing") })
+
+	before := audit.CurrentStats()
+	for i := 0; i < 3; i++ {
+		audit.Log(audit.JournalEntry{
+			CorrelationId: fmt.Sprintf("cid-%d", i), Event: audit.EventOk,
+		})
+	}
+	after := audit.CurrentStats()
+
+	if n := len(sink.Entries()); n != 3 {
+		t.Errorf("want 3 entries, got %d", n)
+	}
+	if d := after.Failed - before.Failed; d != 3 {
+		t.Errorf("want 3 failed writes, got %d", d)
+	}
+	if d := after.Delivered - before.Delivered; d != 3 {
+		t.Errorf("want 3 delivered writes, got %d", d)
+	}
+	text := logs.text()
+	if !strings.Contains(text, "cid-2 audit: failed to write to sink sink-test-failing disk full") {
+		t.Errorf("want the failure to be logged, got %q", text)
+	}
+}
+
+func TestLogIgnoresTheLogLevel(t *testing.T) {
+	level := log.GetLevel()
+	log.SetLevel(log.Off)
+	t.Cleanup(func() { log.SetLevel(level) })
+
+	sink := audit.NewMemorySink()
+	withSink(t, "sink-test-level", sink)
+	audit.Log(audit.JournalEntry{CorrelationId: "cid-0", Event: audit.EventOk})
+
+	if e := sink.Entries(); len(e) != 1 || e[0].CorrelationId != "cid-0" {
+		t.Errorf("want the entry to be delivered with logging off, got %+v", e)
+	}
+}
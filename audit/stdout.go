@@ -0,0 +1,43 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// StdoutSink writes audit journal entries to the standard output, as one
+// JSON Record per line.
+type StdoutSink struct {
+	mux sync.Mutex
+	out io.Writer
+}
+
+// NewStdoutSink creates a Sink that writes to os.Stdout.
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{out: os.Stdout}
+}
+
+func (s *StdoutSink) Write(e JournalEntry) error {
+	b, err := encode(e)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, err = s.out.Write(b)
+	return err
+}
+
+func (s *StdoutSink) Close() error {
+	return nil
+}
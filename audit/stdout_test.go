@@ -0,0 +1,68 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"github.com/zerotohero-dev/aegis-core/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stdoutSink returns a StdoutSink whose standard output is the file at the
+// returned path.
+func stdoutSink(t *testing.T) (*audit.StdoutSink, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdout")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = f
+	s := audit.NewStdoutSink()
+	os.Stdout = stdout
+	t.Cleanup(func() { _ = f.Close() })
+	return s, path
+}
+
+func TestStdoutSink(t *testing.T) {
+	s, path := stdoutSink(t)
+	writeEntries(t, s, 0, 2)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0,cid-1" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestStdoutSinkIgnoresTheLogLevel(t *testing.T) {
+	level := log.GetLevel()
+	log.SetLevel(log.Off)
+	t.Cleanup(func() { log.SetLevel(level) })
+
+	s, path := stdoutSink(t)
+	withSink(t, "stdout-test-level", s)
+	audit.Log(audit.JournalEntry{CorrelationId: "cid-0", Event: audit.EventOk})
+
+	p, err := audit.SearchFile(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(p); got != "cid-0" {
+		t.Errorf("want the entry on the standard output with logging off, got %q", got)
+	}
+}
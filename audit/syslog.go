@@ -0,0 +1,274 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog facilities that suit audit records. See RFC 5424, Section 6.2.1.
+const FacilityAuth = 4
+const FacilityAuthPriv = 10
+const FacilityLocal0 = 16
+
+// Syslog severities that the SyslogSink uses.
+const severityWarning = 4
+const severityInfo = 6
+
+// syslogTimeFormat has microsecond precision, the most that RFC 5424
+// allows (TIME-SECFRAC is at most 6 digits).
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// SyslogSdId is the SD-ID of the structured-data element that carries the
+// audit fields. 32473 is the private enterprise number that RFC 5612
+// reserves for documentation and examples.
+const SyslogSdId = "aegis@32473"
+
+// errSyslogClosed is returned when writing to a closed SyslogSink.
+var errSyslogClosed = errors.New("audit: syslog sink is closed")
+
+// SyslogConfig configures a SyslogSink.
+type SyslogConfig struct {
+	// "udp", "tcp", or "tls".
+	Network string
+	// host:port of the syslog collector.
+	Address string
+	// Used when Network is "tls".
+	TlsConfig *tls.Config
+	// Defaults to FacilityAuthPriv.
+	Facility int
+	// Defaults to the host name that the kernel reports.
+	Hostname string
+	// Defaults to "aegis-safe".
+	AppName string
+	// Defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+// SyslogSink sends audit journal entries to a syslog collector, as RFC 5424
+// messages. Over TCP and TLS, the messages are framed with octet counting,
+// as RFC 6587 and RFC 5425 describe; over UDP, each message is a datagram.
+//
+// The svid, the event, and the correlation id of the entry are sent as
+// structured data (see SyslogSdId); the message itself is the JSON Record.
+//
+// If the collector has closed the connection, or writing to the connection
+// fails, the sink reconnects and retries the write once, before reporting
+// the failure.
+type SyslogSink struct {
+	mux    sync.Mutex
+	config SyslogConfig
+	conn   net.Conn
+	procId string
+	closed bool
+}
+
+// NewSyslogSink creates a SyslogSink, and connects to the collector.
+func NewSyslogSink(c SyslogConfig) (*SyslogSink, error) {
+	switch c.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("audit: unsupported syslog network %q", c.Network)
+	}
+	if c.Facility == 0 {
+		c.Facility = FacilityAuthPriv
+	}
+	if c.Hostname == "" {
+		c.Hostname, _ = os.Hostname()
+	}
+	if c.AppName == "" {
+		c.AppName = "aegis-safe"
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 5 * time.Second
+	}
+
+	s := &SyslogSink{config: c, procId: strconv.Itoa(os.Getpid())}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) Write(e JournalEntry) error {
+	msg, err := s.format(e)
+	if err != nil {
+		return err
+	}
+	if s.config.Network != "udp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return errSyslogClosed
+	}
+	if s.conn != nil && !s.alive() {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	if s.conn != nil {
+		if err = s.send(msg); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.send(msg)
+}
+
+func (s *SyslogSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *SyslogSink) connect() error {
+	d := &net.Dialer{Timeout: s.config.Timeout}
+
+	var conn net.Conn
+	var err error
+	if s.config.Network == "tls" {
+		conn, err = tls.DialWithDialer(
+			d, "tcp", s.config.Address, s.config.TlsConfig,
+		)
+	} else {
+		conn, err = d.Dial(s.config.Network, s.config.Address)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	return nil
+}
+
+// alive returns false if the collector has closed the connection, such as
+// when it restarts. A write to such a connection can succeed, and the
+// message be lost, so the sink checks before writing. Collectors do not send
+// anything over the connection, so a read that does not time out means that
+// the connection is closed.
+func (s *SyslogSink) alive() bool {
+	if s.config.Network == "udp" {
+		return true
+	}
+	if err := s.conn.SetReadDeadline(
+		time.Now().Add(time.Millisecond),
+	); err != nil {
+		return false
+	}
+	var b [1]byte
+	_, err := s.conn.Read(b[:])
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func (s *SyslogSink) send(msg []byte) error {
+	if err := s.conn.SetWriteDeadline(
+		time.Now().Add(s.config.Timeout),
+	); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// format renders e as an RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ELEMENT] MSG
+func (s *SyslogSink) format(e JournalEntry) ([]byte, error) {
+	r := NewRecord(e)
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	severity := severityWarning
+	switch r.Event {
+	case EventOk, EventEnter:
+		severity = severityInfo
+	case EventExit:
+		if r.Status < 400 {
+			severity = severityInfo
+		}
+	}
+
+	timestamp := "-"
+	if !r.Timestamp.IsZero() {
+		timestamp = r.Timestamp.Format(syslogTimeFormat)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(
+		"<%d>1 %s %s %s %s %s ",
+		s.config.Facility*8+severity,
+		timestamp,
+		headerField(s.config.Hostname, 255),
+		headerField(s.config.AppName, 48),
+		headerField(s.procId, 128),
+		headerField(string(r.Event), 32),
+	))
+	b.WriteString(fmt.Sprintf(
+		`[%s svid="%s" event="%s" correlationId="%s"] `,
+		SyslogSdId,
+		sdParamValue(r.Svid),
+		sdParamValue(string(r.Event)),
+		sdParamValue(r.CorrelationId),
+	))
+	// The BOM marks the message as UTF-8 encoded.
+	b.WriteString("\xef\xbb\xbf")
+	b.Write(body)
+
+	return []byte(b.String()), nil
+}
+
+// headerField returns v as a valid RFC 5424 header field: printable US-ASCII
+// only, at most maxLen characters, and the NILVALUE if empty.
+func headerField(v string, maxLen int) string {
+	f := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, v)
+	if len(f) > maxLen {
+		f = f[:maxLen]
+	}
+	if f == "" {
+		return "-"
+	}
+	return f
+}
+
+// sdParamValue escapes the characters that RFC 5424 requires escaping in
+// structured-data parameter values.
+func sdParamValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
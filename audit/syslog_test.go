@@ -0,0 +1,423 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package audit_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"io"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syslogCollector is a stand-in for a syslog collector. It sends every
+// message that it receives to messages, without the framing.
+type syslogCollector struct {
+	network  string
+	address  string
+	tls      *tls.Config
+	messages chan string
+
+	mux      sync.Mutex
+	listener net.Listener
+	packet   net.PacketConn
+	conns    []net.Conn
+}
+
+func newSyslogCollector(t *testing.T, network string) *syslogCollector {
+	c := &syslogCollector{
+		network:  network,
+		address:  "127.0.0.1:0",
+		messages: make(chan string, 100),
+	}
+	if network == "tls" {
+		c.tls = serverTlsConfig(t)
+	}
+	c.start(t)
+	t.Cleanup(c.stop)
+	return c
+}
+
+func (c *syslogCollector) start(t *testing.T) {
+	t.Helper()
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.network == "udp" {
+		p, err := net.ListenPacket("udp", c.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.packet = p
+		c.address = p.LocalAddr().String()
+		go func() {
+			buf := make([]byte, 64*1024)
+			for {
+				n, _, err := p.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				c.messages <- string(buf[:n])
+			}
+		}()
+		return
+	}
+
+	l, err := net.Listen("tcp", c.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.tls != nil {
+		l = tls.NewListener(l, c.tls)
+	}
+	c.listener = l
+	c.address = l.Addr().String()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.mux.Lock()
+			c.conns = append(c.conns, conn)
+			c.mux.Unlock()
+			go c.readFrames(conn)
+		}
+	}()
+}
+
+// readFrames reads octet-counted frames (RFC 6587, Section 3.4.1).
+func (c *syslogCollector) readFrames(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		length, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			c.messages <- "bad frame length: " + length
+			return
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return
+		}
+		c.messages <- string(msg)
+	}
+}
+
+// stop closes the listener, and every connection that it has accepted.
+func (c *syslogCollector) stop() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.packet != nil {
+		_ = c.packet.Close()
+	}
+	if c.listener != nil {
+		_ = c.listener.Close()
+	}
+	for _, conn := range c.conns {
+		_ = conn.Close()
+	}
+	c.conns = nil
+}
+
+func (c *syslogCollector) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case m := <-c.messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("the collector has received no message")
+		return ""
+	}
+}
+
+// serverTlsConfig returns a TLS configuration with a self-signed
+// certificate for 127.0.0.1.
+func serverTlsConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "syslog"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{
+		{Certificate: [][]byte{der}, PrivateKey: key},
+	}}
+}
+
+func clientTlsConfig(c *syslogCollector) *tls.Config {
+	pool := x509.NewCertPool()
+	cert, _ := x509.ParseCertificate(c.tls.Certificates[0].Certificate[0])
+	pool.AddCert(cert)
+	return &tls.Config{RootCAs: pool}
+}
+
+func newSyslogSink(t *testing.T, c *syslogCollector) *audit.SyslogSink {
+	t.Helper()
+	config := audit.SyslogConfig{
+		Network:  c.network,
+		Address:  c.address,
+		Hostname: "host-1",
+		AppName:  "aegis-test",
+		Timeout:  time.Second,
+	}
+	if c.tls != nil {
+		config.TlsConfig = clientTlsConfig(c)
+	}
+	s, err := audit.NewSyslogSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+var syslogRe = regexp.MustCompile(
+	`^<(\d+)>1 (\S+) (\S+) (\S+) (\S+) (\S+) ` +
+		`\[aegis@32473 svid="((?:[^"\\\]]|\\.)*)" event="((?:[^"\\\]]|\\.)*)" ` +
+		`correlationId="((?:[^"\\\]]|\\.)*)"\] \x{FEFF}(.*)$`,
+)
+
+type syslogMessage struct {
+	pri, timestamp, hostname, appName, procId, msgId string
+	// The raw, escaped, structured-data parameter values.
+	svid, event, correlationId string
+	record                     audit.Record
+}
+
+func parseSyslog(t *testing.T, m string) syslogMessage {
+	t.Helper()
+	p := syslogRe.FindStringSubmatch(m)
+	if p == nil {
+		t.Fatalf("not an RFC 5424 message: %q", m)
+	}
+	s := syslogMessage{
+		pri: p[1], timestamp: p[2], hostname: p[3], appName: p[4],
+		procId: p[5], msgId: p[6],
+		svid: p[7], event: p[8], correlationId: p[9],
+	}
+	if err := json.Unmarshal([]byte(p[10]), &s.record); err != nil {
+		t.Fatalf("the message is not an audit record: %s", err.Error())
+	}
+	return s
+}
+
+var entryTime = time.Date(2023, 4, 1, 10, 20, 30, 123000000, time.UTC)
+
+func TestSyslogSink(t *testing.T) {
+	for _, network := range []string{"udp", "tcp", "tls"} {
+		t.Run(network, func(t *testing.T) {
+			c := newSyslogCollector(t, network)
+			s := newSyslogSink(t, c)
+
+			if err := s.Write(audit.JournalEntry{
+				CorrelationId: "cid-1",
+				Svid:          "spiffe://aegis.ist/workload/example",
+				Event:         audit.EventOk,
+				Time:          entryTime,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Write(audit.JournalEntry{
+				CorrelationId: "cid-2",
+				Event:         audit.EventExit,
+				Status:        500,
+				Time:          entryTime,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			// authpriv (10) * 8 + informational (6)
+			m := parseSyslog(t, c.next(t))
+			if m.pri != "86" {
+				t.Errorf("PRI: got %s, want 86", m.pri)
+			}
+			if m.timestamp != "2023-04-01T10:20:30.123000Z" {
+				t.Errorf("TIMESTAMP: got %s", m.timestamp)
+			}
+			if m.hostname != "host-1" || m.appName != "aegis-test" {
+				t.Errorf("HOSTNAME, APP-NAME: got %s, %s", m.hostname, m.appName)
+			}
+			if _, err := strconv.Atoi(m.procId); err != nil {
+				t.Errorf("PROCID: got %s, want the process id", m.procId)
+			}
+			if m.msgId != string(audit.EventOk) || m.event != m.msgId {
+				t.Errorf("MSGID, event: got %s, %s", m.msgId, m.event)
+			}
+			if m.svid != "spiffe://aegis.ist/workload/example" ||
+				m.correlationId != "cid-1" {
+				t.Errorf("structured data: got %+v", m)
+			}
+			if m.record.CorrelationId != "cid-1" {
+				t.Errorf("record: got %+v", m.record)
+			}
+
+			// authpriv (10) * 8 + warning (4)
+			m = parseSyslog(t, c.next(t))
+			if m.pri != "84" || m.record.Status != 500 {
+				t.Errorf("got PRI %s, status %d", m.pri, m.record.Status)
+			}
+		})
+	}
+}
+
+func TestSyslogSinkTimestampPrecision(t *testing.T) {
+	c := newSyslogCollector(t, "udp")
+	s := newSyslogSink(t, c)
+
+	for _, tt := range []struct {
+		time time.Time
+		want string
+	}{
+		{
+			time.Date(2023, 4, 1, 10, 20, 30, 123456789, time.UTC),
+			"2023-04-01T10:20:30.123456Z",
+		},
+		{
+			time.Date(2023, 4, 1, 10, 20, 30, 0, time.FixedZone("", 3600)),
+			"2023-04-01T09:20:30.000000Z",
+		},
+	} {
+		if err := s.Write(audit.JournalEntry{Time: tt.time}); err != nil {
+			t.Fatal(err)
+		}
+		if m := parseSyslog(t, c.next(t)); m.timestamp != tt.want {
+			t.Errorf("TIMESTAMP: got %s, want %s", m.timestamp, tt.want)
+		}
+	}
+}
+
+func TestSyslogSinkEscapesStructuredData(t *testing.T) {
+	c := newSyslogCollector(t, "tcp")
+	s := newSyslogSink(t, c)
+
+	if err := s.Write(audit.JournalEntry{
+		CorrelationId: `a"b`,
+		Svid:          `spiffe://aegis.ist/x\y]z`,
+		Event:         audit.EventOk,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := parseSyslog(t, c.next(t))
+	if want := `spiffe://aegis.ist/x\\y\]z`; m.svid != want {
+		t.Errorf("svid: got %s, want %s", m.svid, want)
+	}
+	if want := `a\"b`; m.correlationId != want {
+		t.Errorf("correlationId: got %s, want %s", m.correlationId, want)
+	}
+	if m.timestamp != "-" {
+		t.Errorf("TIMESTAMP: got %s, want the NILVALUE", m.timestamp)
+	}
+	if m.record.Svid != `spiffe://aegis.ist/x\y]z` {
+		t.Errorf("record svid: got %s", m.record.Svid)
+	}
+}
+
+func TestSyslogSinkHeaderFields(t *testing.T) {
+	c := newSyslogCollector(t, "udp")
+	s, err := audit.NewSyslogSink(audit.SyslogConfig{
+		Network:  "udp",
+		Address:  c.address,
+		Hostname: "host with spaces\n",
+		AppName:  strings.Repeat("a", 60),
+		Facility: audit.FacilityLocal0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Write(audit.JournalEntry{Event: audit.EventBadSvid}); err != nil {
+		t.Fatal(err)
+	}
+	m := parseSyslog(t, c.next(t))
+	if m.hostname != "hostwithspaces" {
+		t.Errorf("HOSTNAME: got %q", m.hostname)
+	}
+	if m.appName != strings.Repeat("a", 48) {
+		t.Errorf("APP-NAME: got %q, want it truncated to 48", m.appName)
+	}
+	// local0 (16) * 8 + warning (4)
+	if m.pri != "132" {
+		t.Errorf("PRI: got %s, want 132", m.pri)
+	}
+}
+
+func TestSyslogSinkReconnects(t *testing.T) {
+	for _, network := range []string{"udp", "tcp", "tls"} {
+		t.Run(network, func(t *testing.T) {
+			c := newSyslogCollector(t, network)
+			s := newSyslogSink(t, c)
+
+			if err := s.Write(audit.JournalEntry{CorrelationId: "before"}); err != nil {
+				t.Fatal(err)
+			}
+			if m := parseSyslog(t, c.next(t)); m.correlationId != "before" {
+				t.Fatalf("got %s", m.correlationId)
+			}
+
+			c.stop()
+			// Let the sink see the connection close.
+			time.Sleep(50 * time.Millisecond)
+			c.start(t)
+
+			if err := s.Write(audit.JournalEntry{CorrelationId: "after"}); err != nil {
+				t.Fatalf("the sink has not reconnected: %s", err.Error())
+			}
+			if m := parseSyslog(t, c.next(t)); m.correlationId != "after" {
+				t.Errorf("got %s", m.correlationId)
+			}
+		})
+	}
+}
+
+func TestSyslogSinkClosed(t *testing.T) {
+	c := newSyslogCollector(t, "tcp")
+	s := newSyslogSink(t, c)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(audit.JournalEntry{}); err == nil {
+		t.Error("Write succeeded after Close")
+	}
+}
+
+func TestSyslogSinkUnsupportedNetwork(t *testing.T) {
+	if _, err := audit.NewSyslogSink(audit.SyslogConfig{Network: "unix"}); err == nil {
+		t.Error("got no error for an unsupported network")
+	}
+}
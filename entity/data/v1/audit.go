@@ -0,0 +1,17 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+// The keys of the audit fields (see audit.Auditable) that the audit package
+// maps to the Error, Created, and Updated fields of its records. They are
+// defined here, rather than in the audit package, so that the entities can
+// use them without importing it.
+const AuditFieldErr = "err"
+const AuditFieldCreated = "created"
+const AuditFieldUpdated = "updated"
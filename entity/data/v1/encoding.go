@@ -0,0 +1,55 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// EncodeValue encodes b as a secret value: as is if it is valid UTF-8, and
+// as its standard base64 encoding otherwise.
+func EncodeValue(b []byte) (string, ValueEncoding) {
+	if utf8.Valid(b) {
+		return string(b), Utf8
+	}
+	return base64.StdEncoding.EncodeToString(b), Base64
+}
+
+// DecodeValue returns the bytes of a secret value with the given encoding.
+// An empty encoding is Utf8.
+func DecodeValue(value string, e ValueEncoding) ([]byte, error) {
+	switch e {
+	case Utf8, "":
+		return []byte(value), nil
+	case Base64:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 value: %s", err.Error())
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown value encoding %q", e)
+	}
+}
+
+// Bytes returns the bytes of the raw value of the secret.
+func (s SecretStored) Bytes() ([]byte, error) {
+	return DecodeValue(s.Value, s.Encoding)
+}
+
+// UpdateBytes is like Update, but takes the value as bytes, which can be
+// binary; see EncodeValue.
+func (s *SecretStored) UpdateBytes(b []byte, maxHistory int, now time.Time) {
+	value, e := EncodeValue(b)
+	s.Update(value, maxHistory, now)
+	s.Encoding = e
+}
@@ -0,0 +1,48 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import "time"
+
+// ExpiresAt returns the time the secret expires, or the zero time if the
+// secret never expires. Meta.ExpiresAt takes precedence over Meta.Ttl.
+func (s SecretStored) ExpiresAt() time.Time {
+	if !s.Meta.ExpiresAt.IsZero() {
+		return s.Meta.ExpiresAt
+	}
+	if s.Meta.Ttl > 0 {
+		return s.Updated.Add(s.Meta.Ttl)
+	}
+	return time.Time{}
+}
+
+// IsExpired returns true if the secret has expired at the given time.
+func (s SecretStored) IsExpired(now time.Time) bool {
+	exp := s.ExpiresAt()
+	return !exp.IsZero() && !now.Before(exp)
+}
+
+// IsActive returns true if the secret is valid at the given time: its
+// Meta.NotBefore time has come, and it has not expired yet.
+func (s SecretStored) IsActive(now time.Time) bool {
+	if !s.Meta.NotBefore.IsZero() && now.Before(s.Meta.NotBefore) {
+		return false
+	}
+	return !s.IsExpired(now)
+}
+
+// IsExpiringSoon returns true if the secret has not expired at the given
+// time, but it will expire within the given duration.
+func (s SecretStored) IsExpiringSoon(now time.Time, within time.Duration) bool {
+	exp := s.ExpiresAt()
+	if exp.IsZero() || !now.Before(exp) {
+		return false
+	}
+	return exp.Sub(now) <= within
+}
@@ -0,0 +1,107 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"testing"
+	"time"
+)
+
+func validFor(notBefore, expiresAt time.Time, ttl time.Duration) SecretStored {
+	return SecretStored{
+		Updated: stamp,
+		Meta: SecretMeta{
+			NotBefore: notBefore,
+			ExpiresAt: expiresAt,
+			Ttl:       ttl,
+		},
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	tests := []struct {
+		name string
+		s    SecretStored
+		want time.Time
+	}{
+		{"never", validFor(time.Time{}, time.Time{}, 0), time.Time{}},
+		{"ttl", validFor(time.Time{}, time.Time{}, time.Hour), stamp.Add(time.Hour)},
+		{"expiresAt", validFor(time.Time{}, stamp.Add(time.Minute), 0), stamp.Add(time.Minute)},
+		{
+			"expiresAt takes precedence over ttl",
+			validFor(time.Time{}, stamp.Add(time.Minute), time.Hour),
+			stamp.Add(time.Minute),
+		},
+		{
+			"expiresAt takes precedence over a shorter ttl",
+			validFor(time.Time{}, stamp.Add(2*time.Hour), time.Hour),
+			stamp.Add(2 * time.Hour),
+		},
+		{"negative ttl", validFor(time.Time{}, time.Time{}, -time.Hour), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.s.ExpiresAt(); !got.Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTtlCountsFromTheLastUpdate(t *testing.T) {
+	var s SecretStored
+	s.Meta.Ttl = time.Hour
+	s.Update("a", 2, stamp)
+	s.Update("b", 2, stamp.Add(30*time.Minute))
+	if want := stamp.Add(90 * time.Minute); !s.ExpiresAt().Equal(want) {
+		t.Errorf("got %v, want %v", s.ExpiresAt(), want)
+	}
+}
+
+func TestValidityPeriod(t *testing.T) {
+	notBefore, expiresAt := stamp.Add(time.Hour), stamp.Add(2*time.Hour)
+	s := validFor(notBefore, expiresAt, 0)
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		active       bool
+		expired      bool
+		expiringSoon bool
+	}{
+		{"before notBefore", notBefore.Add(-time.Nanosecond), false, false, false},
+		{"at notBefore", notBefore, true, false, false},
+		{"within the period", expiresAt.Add(-16 * time.Minute), true, false, false},
+		{"expiring within 15m", expiresAt.Add(-15 * time.Minute), true, false, true},
+		{"expiring soon", expiresAt.Add(-10 * time.Minute), true, false, true},
+		{"just before expiresAt", expiresAt.Add(-time.Nanosecond), true, false, true},
+		{"at expiresAt", expiresAt, false, true, false},
+		{"after expiresAt", expiresAt.Add(time.Hour), false, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := s.IsActive(tt.now); got != tt.active {
+			t.Errorf("%s: active %t, want %t", tt.name, got, tt.active)
+		}
+		if got := s.IsExpired(tt.now); got != tt.expired {
+			t.Errorf("%s: expired %t, want %t", tt.name, got, tt.expired)
+		}
+		if got := s.IsExpiringSoon(tt.now, 15*time.Minute); got != tt.expiringSoon {
+			t.Errorf("%s: expiring soon %t, want %t", tt.name, got, tt.expiringSoon)
+		}
+	}
+}
+
+func TestSecretsWithoutValidityPeriod(t *testing.T) {
+	s := validFor(time.Time{}, time.Time{}, 0)
+	for _, now := range []time.Time{{}, stamp, stamp.Add(100 * 365 * 24 * time.Hour)} {
+		if !s.IsActive(now) || s.IsExpired(now) || s.IsExpiringSoon(now, time.Hour) {
+			t.Errorf("want the secret to be active forever, got inactive at %v", now)
+		}
+	}
+}
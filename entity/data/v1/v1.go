@@ -9,21 +9,60 @@
 package v1
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
 
+// JsonTimeFormat is the time format of the v1 API. It has no sub-second
+// precision; the v2 API uses RFC 3339 instead (see the v2 package).
+const JsonTimeFormat = time.RubyDate
+
 type JsonTime time.Time
 
 func (t JsonTime) MarshalJSON() ([]byte, error) {
-	stamp := fmt.Sprintf("\"%s\"", time.Time(t).Format(time.RubyDate))
+	stamp := fmt.Sprintf("\"%s\"", time.Time(t).Format(JsonTimeFormat))
 	return []byte(stamp), nil
 }
 
+// UnmarshalJSON accepts both Ruby date and RFC 3339 times, so that clients
+// can decode the responses of either API version. null leaves t as is, as
+// with the other JSON types.
+func (t *JsonTime) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	parsed, err := ParseJsonTime(b)
+	if err != nil {
+		return err
+	}
+	*t = JsonTime(parsed)
+	return nil
+}
+
+// ParseJsonTime parses a JSON string that holds either a Ruby date or an
+// RFC 3339 time.
+func ParseJsonTime(b []byte) (time.Time, error) {
+	var stamp string
+	if err := json.Unmarshal(b, &stamp); err != nil {
+		return time.Time{}, fmt.Errorf("JsonTime: %s", err.Error())
+	}
+	for _, layout := range []string{time.RubyDate, time.RFC3339Nano} {
+		if parsed, err := time.Parse(layout, stamp); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(
+		"JsonTime: %q is neither an RFC 3339 nor a Ruby date time", stamp,
+	)
+}
+
 type Secret struct {
-	Name    string   `json:"name"`
-	Created JsonTime `json:"created"`
-	Updated JsonTime `json:"updated"`
+	Name    string            `json:"name"`
+	Version int64             `json:"version"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Created JsonTime          `json:"created"`
+	Updated JsonTime          `json:"updated"`
 }
 
 type BackingStore string
@@ -37,6 +76,20 @@ type SecretFormat string
 var Json SecretFormat = "json"
 var Yaml SecretFormat = "yaml"
 var None SecretFormat = "none"
+var Dotenv SecretFormat = "dotenv"
+var Toml SecretFormat = "toml"
+var Properties SecretFormat = "properties"
+var Ini SecretFormat = "ini"
+
+// ValueEncoding is the encoding of a secret value.
+type ValueEncoding string
+
+// The value is text.
+var Utf8 ValueEncoding = "utf8"
+
+// The value is binary, such as a keystore or a keytab, and is carried as
+// its standard base64 encoding.
+var Base64 ValueEncoding = "base64"
 
 type SecretMeta struct {
 	// Overrides Env.SafeUseKubernetesSecrets()
@@ -50,16 +103,37 @@ type SecretMeta struct {
 	// '{"username":"admin","password":"AegisRocks"}'
 	// Sample template:
 	// '{"USER":"{{.username}}", "PASS":"{{.password}}"}"
-	Template string `json:"template"`
+	Template string `json:"template" aegis:"secret"`
 	// Defaults to None
 	Format SecretFormat
+	// The secret is not valid before this time; zero means no limit.
+	NotBefore time.Time `json:"notBefore"`
+	// The secret is not valid at, or after, this time; zero means no
+	// limit. Takes precedence over Ttl.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// If ExpiresAt is zero, the secret expires Ttl after it was last
+	// updated; zero means it never expires.
+	Ttl time.Duration `json:"ttl"`
+	// Identifying key/value pairs, such as team, environment, or
+	// application, that secrets can be selected by. Keys and values
+	// follow the Kubernetes label syntax; see selector.ValidateLabels.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Non-identifying key/value pairs; these cannot be selected by.
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 type SecretStored struct {
 	// Name of the secret.
 	Name string
 	// Raw value.
-	Value string
+	Value string `aegis:"secret"`
+	// Encoding of Value and ValueTransformed. Defaults to Utf8.
+	Encoding ValueEncoding `json:"encoding,omitempty"`
+	// Keys and values of a structured secret, such as a username and a
+	// password. If set, Value is the JSON object encoding of Values; use
+	// UpdateValues and UpdateKey, which keep the two in sync, instead of
+	// setting either directly.
+	Values map[string]string `json:"values,omitempty" aegis:"secret"`
 	// Transformed value. This value is the value that workloads see.
 	//
 	// Apply transformation (if needed) and then store the value in
@@ -67,10 +141,27 @@ type SecretStored struct {
 	// a valid JSON is stored here. If the format is yaml, ensure that
 	// a valid YAML is stored here. If the format is none, then just
 	// apply transformation (if needed) and do not do any validity check.
-	ValueTransformed string `json:"valueTransformed"`
+	// See transform.Apply.
+	ValueTransformed string `json:"valueTransformed" aegis:"secret"`
 	// Additional information that helps formatting and storing the secret.
 	Meta SecretMeta
 	// Timestamps
 	Created time.Time
 	Updated time.Time
+	// Version of Value. It starts from 1, and increases by one with every
+	// update, including rollbacks.
+	Version int64
+	// Earlier versions of the secret, newest first. The number of versions
+	// kept is bounded by Env.SafeSecretBackupCount().
+	History []SecretVersion
+}
+
+// SecretVersion is an earlier version of a SecretStored.
+type SecretVersion struct {
+	Version          int64             `json:"version"`
+	Value            string            `json:"value" aegis:"secret"`
+	ValueTransformed string            `json:"valueTransformed" aegis:"secret"`
+	Encoding         ValueEncoding     `json:"encoding,omitempty"`
+	Values           map[string]string `json:"values,omitempty" aegis:"secret"`
+	Updated          time.Time         `json:"updated"`
 }
@@ -0,0 +1,105 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stamp = time.Date(2023, 4, 1, 10, 20, 30, 123456789, time.FixedZone("", 3600))
+
+func TestJsonTimeRoundTrip(t *testing.T) {
+	b, err := json.Marshal(JsonTime(stamp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"Sat Apr 01 10:20:30 +0100 2023"`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var got JsonTime
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	// Ruby dates have no sub-second precision.
+	if time.Time(got).Equal(stamp) {
+		t.Errorf("got %v, want the sub-seconds to be lost", time.Time(got))
+	}
+	if !time.Time(got).Equal(stamp.Truncate(time.Second)) {
+		t.Errorf("got %v, want %v", time.Time(got), stamp.Truncate(time.Second))
+	}
+}
+
+func TestJsonTimeUnmarshal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{`"Sat Apr 01 10:20:30 +0100 2023"`, stamp.Truncate(time.Second)},
+		{`"2023-04-01T10:20:30+01:00"`, stamp.Truncate(time.Second)},
+		{`"2023-04-01T09:20:30.123456789Z"`, stamp},
+	}
+	for _, tt := range tests {
+		var got JsonTime
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("%s: %s", tt.in, err.Error())
+			continue
+		}
+		if !time.Time(got).Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.in, time.Time(got), tt.want)
+		}
+	}
+}
+
+func TestJsonTimeUnmarshalNull(t *testing.T) {
+	got := JsonTime(stamp)
+	if err := json.Unmarshal([]byte("null"), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !time.Time(got).Equal(stamp) {
+		t.Errorf("null changed the time to %v", time.Time(got))
+	}
+}
+
+func TestJsonTimeUnmarshalErrors(t *testing.T) {
+	for _, in := range []string{`"yesterday"`, `""`, `5`, `"2023-04-01"`} {
+		var got JsonTime
+		err := json.Unmarshal([]byte(in), &got)
+		if err == nil || !strings.Contains(err.Error(), "JsonTime") {
+			t.Errorf("%s: got %v, want a JsonTime error", in, err)
+		}
+	}
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	s := Secret{
+		Name:    "example",
+		Version: 2,
+		Labels:  map[string]string{"env": "prod"},
+		Created: JsonTime(stamp),
+		Updated: JsonTime(stamp.Add(time.Hour)),
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Secret
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != s.Name || got.Version != s.Version ||
+		got.Labels["env"] != "prod" ||
+		!time.Time(got.Created).Equal(stamp.Truncate(time.Second)) ||
+		!time.Time(got.Updated).Equal(stamp.Add(time.Hour).Truncate(time.Second)) {
+		t.Errorf("got %+v, want %+v", got, s)
+	}
+}
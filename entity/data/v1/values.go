@@ -0,0 +1,112 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrKeyNotFound is returned when a secret has no value with the given key.
+var ErrKeyNotFound = errors.New("secret key not found")
+
+// IsStructured returns true if the secret has keys and values, instead of a
+// single opaque value.
+func (s SecretStored) IsStructured() bool {
+	return len(s.Values) > 0
+}
+
+// Keys returns the keys of the values of the secret, sorted.
+func (s SecretStored) Keys() []string {
+	keys := make([]string, 0, len(s.Values))
+	for k := range s.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Key returns the value of the secret with the given key, or
+// ErrKeyNotFound.
+func (s SecretStored) Key(key string) (string, error) {
+	v, ok := s.Values[key]
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	return v, nil
+}
+
+// UpdateValues is like Update, but sets the keys and values of the secret;
+// Value becomes their JSON object encoding.
+func (s *SecretStored) UpdateValues(
+	values map[string]string, maxHistory int, now time.Time,
+) {
+	c := make(map[string]string, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	// Marshaling a map of strings cannot fail; and it sorts the keys, so
+	// that equal values have equal encodings. c is never nil, so no values
+	// are encoded as `{}` rather than `null`.
+	b, _ := json.Marshal(c)
+	s.Update(string(b), maxHistory, now)
+	if len(c) > 0 {
+		s.Values = c
+	}
+}
+
+// UpdateKey sets the value with the given key, keeping the other values, as
+// a new version of the secret. If the secret has a single opaque value that
+// is a JSON object of strings, such as a secret stored before keys and
+// values were supported, its keys are kept too.
+func (s *SecretStored) UpdateKey(
+	key, value string, maxHistory int, now time.Time,
+) {
+	values := s.currentValues()
+	values[key] = value
+	s.UpdateValues(values, maxHistory, now)
+}
+
+// DeleteKey removes the value with the given key, as a new version of the
+// secret. It returns ErrKeyNotFound, and does not create a version, if there
+// is no such value.
+func (s *SecretStored) DeleteKey(key string, maxHistory int, now time.Time) error {
+	values := s.currentValues()
+	if _, ok := values[key]; !ok {
+		return ErrKeyNotFound
+	}
+	delete(values, key)
+	s.UpdateValues(values, maxHistory, now)
+	return nil
+}
+
+// currentValues returns a copy of the values of the secret.
+func (s SecretStored) currentValues() map[string]string {
+	values := map[string]string{}
+	if s.IsStructured() {
+		for k, v := range s.Values {
+			values[k] = v
+		}
+		return values
+	}
+	if s.Encoding == Base64 {
+		return values
+	}
+	// Not an error if Value is not a JSON object of strings: it is
+	// replaced as a whole.
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(s.Value), &parsed); err == nil {
+		for k, v := range parsed {
+			values[k] = v
+		}
+	}
+	return values
+}
@@ -0,0 +1,141 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateValues(t *testing.T) {
+	var s SecretStored
+	values := map[string]string{"user": "admin", "pass": `p"w`}
+	s.UpdateValues(values, 2, stamp)
+
+	if s.Value != `{"pass":"p\"w","user":"admin"}` {
+		t.Errorf("got value %s", s.Value)
+	}
+	if !s.IsStructured() || strings.Join(s.Keys(), ",") != "pass,user" {
+		t.Errorf("got keys %v", s.Keys())
+	}
+	// The secret keeps a copy of the values.
+	values["user"] = "root"
+	if v, _ := s.Key("user"); v != "admin" {
+		t.Errorf("got user %q, want admin", v)
+	}
+	if _, err := s.Key("host"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("want ErrKeyNotFound, got %v", err)
+	}
+
+	// An opaque value replaces the values.
+	s.Update("opaque", 2, stamp.Add(time.Hour))
+	if s.IsStructured() || len(s.Keys()) != 0 {
+		t.Errorf("want no values, got %v", s.Keys())
+	}
+	v, err := s.AtVersion(1)
+	if err != nil || v.Values["user"] != "admin" {
+		t.Errorf("want the history to keep the values, got %+v, %v", v, err)
+	}
+
+	s.UpdateValues(nil, 2, stamp.Add(2*time.Hour))
+	if s.Value != "{}" || s.IsStructured() {
+		t.Errorf("got value %s, structured %t", s.Value, s.IsStructured())
+	}
+}
+
+func TestUpdateKey(t *testing.T) {
+	var s SecretStored
+	s.UpdateValues(map[string]string{"user": "admin"}, 2, stamp)
+	s.UpdateKey("pass", "hunter2", 2, stamp.Add(time.Hour))
+	s.UpdateKey("user", "root", 2, stamp.Add(2*time.Hour))
+
+	if s.Version != 3 || s.Value != `{"pass":"hunter2","user":"root"}` {
+		t.Errorf("got version %d, value %s", s.Version, s.Value)
+	}
+	if strings.Join(s.Keys(), ",") != "pass,user" {
+		t.Errorf("got keys %v", s.Keys())
+	}
+	// Updating a key must not change the values of the earlier versions.
+	if v, _ := s.AtVersion(1); len(v.Values) != 1 || v.Values["user"] != "admin" {
+		t.Errorf("got version 1 values %v", v.Values)
+	}
+}
+
+func TestUpdateKeyUpgradesLegacyValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"json object of strings", `{"user":"admin"}`, `{"pass":"hunter2","user":"admin"}`},
+		{"json object of other values", `{"port":5432}`, `{"pass":"hunter2"}`},
+		{"json string", `"admin"`, `{"pass":"hunter2"}`},
+		{"opaque", "admin", `{"pass":"hunter2"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s SecretStored
+			s.Update(tt.value, 2, stamp)
+			s.UpdateKey("pass", "hunter2", 2, stamp.Add(time.Hour))
+			if s.Value != tt.want || !s.IsStructured() {
+				t.Errorf("got value %s, want %s", s.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateKeyOfABinaryValue(t *testing.T) {
+	var s SecretStored
+	// Base64 of a JSON object; the keys of binary values are not read.
+	s.Update(`eyJ1c2VyIjoiYWRtaW4ifQ==`, 2, stamp)
+	s.Encoding = Base64
+	s.UpdateKey("pass", "hunter2", 2, stamp.Add(time.Hour))
+
+	if s.Value != `{"pass":"hunter2"}` || s.Encoding != Utf8 {
+		t.Errorf("got value %s, encoding %s", s.Value, s.Encoding)
+	}
+}
+
+func TestDeleteKey(t *testing.T) {
+	var s SecretStored
+	s.UpdateValues(map[string]string{"user": "admin", "pass": "hunter2"}, 2, stamp)
+
+	if err := s.DeleteKey("host", 2, stamp.Add(time.Hour)); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("want ErrKeyNotFound, got %v", err)
+	}
+	if s.Version != 1 {
+		t.Errorf("want no new version for a missing key, got %d", s.Version)
+	}
+
+	if err := s.DeleteKey("pass", 2, stamp.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 2 || s.Value != `{"user":"admin"}` {
+		t.Errorf("got version %d, value %s", s.Version, s.Value)
+	}
+	if err := s.DeleteKey("user", 2, stamp.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Value != "{}" || s.IsStructured() {
+		t.Errorf("got value %s, structured %t", s.Value, s.IsStructured())
+	}
+
+	// Keys of legacy values can be deleted too.
+	var legacy SecretStored
+	legacy.Update(`{"user":"admin","pass":"hunter2"}`, 2, stamp)
+	if err := legacy.DeleteKey("pass", 2, stamp.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Value != `{"user":"admin"}` {
+		t.Errorf("got value %s", legacy.Value)
+	}
+}
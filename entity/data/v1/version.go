@@ -0,0 +1,105 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrVersionNotFound is returned when a version of a secret is neither the
+// current version, nor in the history of the secret.
+var ErrVersionNotFound = errors.New("secret version not found")
+
+// Update sets the value of the secret, and moves the previous value to the
+// history of the secret. At most maxHistory earlier versions are kept; pass
+// Env.SafeSecretBackupCount() as maxHistory.
+//
+// Update resets ValueTransformed; transform the new value after calling it.
+// It also resets Encoding to Utf8, and clears Values; set Encoding after
+// calling it if value is base64-encoded, or use UpdateBytes or UpdateValues.
+func (s *SecretStored) Update(value string, maxHistory int, now time.Time) {
+	if s.Version == 0 {
+		s.Version = 1
+		s.Value = value
+		s.ValueTransformed = ""
+		s.Encoding = Utf8
+		s.Values = nil
+		s.Created = now
+		s.Updated = now
+		return
+	}
+
+	if maxHistory < 0 {
+		maxHistory = 0
+	}
+	// Build a new slice rather than trimming the old one, so that the
+	// values of the dropped versions do not linger in its backing array.
+	history := make([]SecretVersion, 0, maxHistory)
+	if maxHistory > 0 {
+		history = append(history, SecretVersion{
+			Version:          s.Version,
+			Value:            s.Value,
+			ValueTransformed: s.ValueTransformed,
+			Encoding:         s.Encoding,
+			Values:           s.Values,
+			Updated:          s.Updated,
+		})
+	}
+	for _, v := range s.History {
+		if len(history) == maxHistory {
+			break
+		}
+		history = append(history, v)
+	}
+	s.History = history
+
+	s.Version++
+	s.Value = value
+	s.ValueTransformed = ""
+	s.Encoding = Utf8
+	s.Values = nil
+	s.Updated = now
+}
+
+// AtVersion returns the given version of the secret, which can be either the
+// current version or one in the history of the secret.
+func (s SecretStored) AtVersion(version int64) (SecretVersion, error) {
+	if version == s.Version {
+		return SecretVersion{
+			Version:          s.Version,
+			Value:            s.Value,
+			ValueTransformed: s.ValueTransformed,
+			Encoding:         s.Encoding,
+			Values:           s.Values,
+			Updated:          s.Updated,
+		}, nil
+	}
+	for _, v := range s.History {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return SecretVersion{}, ErrVersionNotFound
+}
+
+// Rollback restores the value of the given version of the secret. Rolling
+// back does not rewrite the history: the restored value becomes a new
+// version, and the current value is moved to the history, as Update does.
+func (s *SecretStored) Rollback(version int64, maxHistory int, now time.Time) error {
+	v, err := s.AtVersion(version)
+	if err != nil {
+		return err
+	}
+	s.Update(v.Value, maxHistory, now)
+	s.ValueTransformed = v.ValueTransformed
+	s.Encoding = v.Encoding
+	s.Values = v.Values
+	return nil
+}
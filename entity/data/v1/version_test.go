@@ -0,0 +1,175 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// updated returns a secret that has been updated to values, in order, an
+// hour apart.
+func updated(maxHistory int, values ...string) SecretStored {
+	var s SecretStored
+	for i, v := range values {
+		s.Update(v, maxHistory, stamp.Add(time.Duration(i)*time.Hour))
+	}
+	return s
+}
+
+func historyValues(s SecretStored) []string {
+	var values []string
+	for _, v := range s.History {
+		values = append(values, v.Value)
+	}
+	return values
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdate(t *testing.T) {
+	s := updated(2, "a")
+	if s.Version != 1 || s.Value != "a" || len(s.History) != 0 {
+		t.Fatalf("got version %d, value %q, history %v",
+			s.Version, s.Value, historyValues(s))
+	}
+	if !s.Created.Equal(stamp) || !s.Updated.Equal(stamp) {
+		t.Errorf("got created %v, updated %v", s.Created, s.Updated)
+	}
+
+	s.ValueTransformed = "A"
+	s.Encoding = Base64
+	s.Update("b", 2, stamp.Add(time.Hour))
+	if s.Version != 2 || s.Value != "b" {
+		t.Errorf("got version %d, value %q", s.Version, s.Value)
+	}
+	if s.ValueTransformed != "" || s.Encoding != Utf8 {
+		t.Errorf("want the transformed value and the encoding to be reset")
+	}
+	if !s.Created.Equal(stamp) || !s.Updated.Equal(stamp.Add(time.Hour)) {
+		t.Errorf("got created %v, updated %v", s.Created, s.Updated)
+	}
+	v := s.History[0]
+	if v.Version != 1 || v.Value != "a" || v.ValueTransformed != "A" ||
+		v.Encoding != Base64 || !v.Updated.Equal(stamp) {
+		t.Errorf("got history %+v", v)
+	}
+}
+
+func TestUpdateBoundsTheHistory(t *testing.T) {
+	tests := []struct {
+		maxHistory int
+		history    []string
+	}{
+		{maxHistory: 0, history: nil},
+		{maxHistory: -1, history: nil},
+		{maxHistory: 1, history: []string{"c"}},
+		{maxHistory: 2, history: []string{"c", "b"}},
+		{maxHistory: 5, history: []string{"c", "b", "a"}},
+	}
+
+	for _, tt := range tests {
+		s := updated(tt.maxHistory, "a", "b", "c", "d")
+		if s.Version != 4 || s.Value != "d" {
+			t.Errorf("maxHistory %d: got version %d, value %q",
+				tt.maxHistory, s.Version, s.Value)
+		}
+		if got := historyValues(s); !equalValues(got, tt.history) {
+			t.Errorf("maxHistory %d: got history %v, want %v",
+				tt.maxHistory, got, tt.history)
+		}
+	}
+}
+
+func TestUpdateDropsTrimmedValues(t *testing.T) {
+	s := updated(2, "a", "b", "c", "d")
+
+	// The dropped versions must not be reachable through the backing array
+	// of the history either.
+	for _, v := range s.History[:cap(s.History)] {
+		if v.Value == "a" {
+			t.Fatalf("the value of a dropped version is still in memory")
+		}
+	}
+}
+
+func TestAtVersion(t *testing.T) {
+	s := updated(2, "a", "b", "c", "d")
+
+	for version, value := range map[int64]string{4: "d", 3: "c", 2: "b"} {
+		v, err := s.AtVersion(version)
+		if err != nil {
+			t.Errorf("version %d: %v", version, err)
+			continue
+		}
+		if v.Version != version || v.Value != value {
+			t.Errorf("version %d: got %+v", version, v)
+		}
+	}
+	for _, version := range []int64{0, 1, 5} {
+		if _, err := s.AtVersion(version); !errors.Is(err, ErrVersionNotFound) {
+			t.Errorf("version %d: want ErrVersionNotFound, got %v", version, err)
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	s := updated(2, "a", "b", "c", "d")
+	s.History[1].Encoding = Base64
+	s.History[1].ValueTransformed = "B"
+
+	if err := s.Rollback(1, 2, stamp.Add(time.Hour)); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("want a trimmed version to be ErrVersionNotFound, got %v", err)
+	}
+	if s.Version != 4 {
+		t.Fatalf("want a failed rollback to leave the secret alone")
+	}
+
+	now := stamp.Add(10 * time.Hour)
+	if err := s.Rollback(2, 2, now); err != nil {
+		t.Fatal(err)
+	}
+	// Rolling back creates a new version rather than rewriting the history.
+	if s.Version != 5 || s.Value != "b" || !s.Updated.Equal(now) {
+		t.Errorf("got version %d, value %q, updated %v", s.Version, s.Value, s.Updated)
+	}
+	if s.Encoding != Base64 || s.ValueTransformed != "B" {
+		t.Errorf("want the encoding and the transformed value to be restored")
+	}
+	if got := historyValues(s); !equalValues(got, []string{"d", "c"}) {
+		t.Errorf("got history %v", got)
+	}
+}
+
+func TestRollbackWithoutHistory(t *testing.T) {
+	s := updated(0, "a", "b")
+
+	if err := s.Rollback(1, 0, stamp); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("want ErrVersionNotFound, got %v", err)
+	}
+	// The current version can always be restored.
+	if err := s.Rollback(2, 0, stamp); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 3 || s.Value != "b" || len(s.History) != 0 {
+		t.Errorf("got version %d, value %q, history %v",
+			s.Version, s.Value, historyValues(s))
+	}
+}
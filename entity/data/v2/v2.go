@@ -0,0 +1,60 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v2
+
+import (
+	"fmt"
+	v1 "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"time"
+)
+
+// JsonTimeFormat is the time format of the v2 API: RFC 3339, with
+// sub-second precision when the time has it.
+const JsonTimeFormat = time.RFC3339Nano
+
+type JsonTime time.Time
+
+func (t JsonTime) MarshalJSON() ([]byte, error) {
+	stamp := fmt.Sprintf("\"%s\"", time.Time(t).Format(JsonTimeFormat))
+	return []byte(stamp), nil
+}
+
+// UnmarshalJSON accepts both RFC 3339 and Ruby date times; see
+// v1.ParseJsonTime.
+func (t *JsonTime) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	parsed, err := v1.ParseJsonTime(b)
+	if err != nil {
+		return err
+	}
+	*t = JsonTime(parsed)
+	return nil
+}
+
+// Secret is v1.Secret, with RFC 3339 times.
+type Secret struct {
+	Name    string            `json:"name"`
+	Version int64             `json:"version"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Created JsonTime          `json:"created"`
+	Updated JsonTime          `json:"updated"`
+}
+
+// FromV1 converts a v1 Secret to a v2 Secret.
+func FromV1(s v1.Secret) Secret {
+	return Secret{
+		Name:    s.Name,
+		Version: s.Version,
+		Labels:  s.Labels,
+		Created: JsonTime(s.Created),
+		Updated: JsonTime(s.Updated),
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v2
+
+import (
+	"encoding/json"
+	v1 "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"sync"
+	"testing"
+	"time"
+)
+
+var stamp = time.Date(2023, 4, 1, 10, 20, 30, 123456789, time.FixedZone("", 3600))
+
+func TestJsonTimeRoundTrip(t *testing.T) {
+	b, err := json.Marshal(JsonTime(stamp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"2023-04-01T10:20:30.123456789+01:00"`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var got JsonTime
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !time.Time(got).Equal(stamp) {
+		t.Errorf("got %v, want %v", time.Time(got), stamp)
+	}
+}
+
+func TestJsonTimeAcceptsV1Times(t *testing.T) {
+	var got JsonTime
+	err := json.Unmarshal([]byte(`"Sat Apr 01 10:20:30 +0100 2023"`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !time.Time(got).Equal(stamp.Truncate(time.Second)) {
+		t.Errorf("got %v", time.Time(got))
+	}
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	s := FromV1(v1.Secret{
+		Name:    "example",
+		Version: 2,
+		Created: v1.JsonTime(stamp),
+		Updated: v1.JsonTime(stamp),
+	})
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Secret
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "example" || got.Version != 2 ||
+		!time.Time(got.Created).Equal(stamp) ||
+		!time.Time(got.Updated).Equal(stamp) {
+		t.Errorf("got %+v", got)
+	}
+
+	// v1 clients can decode v2 secrets too.
+	var old v1.Secret
+	if err := json.Unmarshal(b, &old); err != nil {
+		t.Fatal(err)
+	}
+	if !time.Time(old.Created).Equal(stamp) {
+		t.Errorf("v1: got %v, want %v", time.Time(old.Created), stamp)
+	}
+}
+
+// TestVersionsAreIndependent checks that v1 and v2 times can be served at
+// the same time, each in its own format.
+func TestVersionsAreIndependent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			b, _ := json.Marshal(v1.JsonTime(stamp))
+			if string(b) != `"Sat Apr 01 10:20:30 +0100 2023"` {
+				t.Errorf("v1: got %s", b)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			b, _ := json.Marshal(JsonTime(stamp))
+			if string(b) != `"2023-04-01T10:20:30.123456789+01:00"` {
+				t.Errorf("v2: got %s", b)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,24 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+
+// The methods in this file implement audit.Auditable.
+
+func (r RegisterWorkloadRequest) AuditFields() map[string]string {
+	return map[string]string{
+		data.AuditFieldErr: r.Err,
+		"workloadId":       r.WorkloadId,
+	}
+}
+
+func (r RegisterWorkloadResponse) AuditFields() map[string]string {
+	return map[string]string{data.AuditFieldErr: r.Err}
+}
@@ -0,0 +1,90 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"strconv"
+)
+
+// The methods in this file implement audit.Auditable.
+// They must never return the secret values that the entities carry.
+
+func (r SecretUpsertRequest) AuditFields() map[string]string {
+	return map[string]string{
+		data.AuditFieldErr: r.Err,
+		"workloadId":       r.WorkloadId,
+		"backingStore":     string(r.BackingStore),
+		"namespace":        r.Namespace,
+		"format":           string(r.Format),
+		"encoding":         string(r.Encoding),
+		"valueKey":         r.ValueKey,
+		"notBefore":        r.NotBefore,
+		"expiresAt":        r.ExpiresAt,
+		"ttl":              r.Ttl,
+	}
+}
+
+func (r SecretUpsertResponse) AuditFields() map[string]string {
+	return map[string]string{data.AuditFieldErr: r.Err}
+}
+
+func (r SecretFetchRequest) AuditFields() map[string]string {
+	return map[string]string{
+		data.AuditFieldErr: r.Err,
+		"version":          strconv.FormatInt(r.Version, 10),
+		"valueKey":         r.ValueKey,
+	}
+}
+
+func (r SecretFetchResponse) AuditFields() map[string]string {
+	return map[string]string{
+		data.AuditFieldErr:     r.Err,
+		"encoding":             string(r.Encoding),
+		"version":              strconv.FormatInt(r.Version, 10),
+		data.AuditFieldCreated: r.Created,
+		data.AuditFieldUpdated: r.Updated,
+		"notBefore":            r.NotBefore,
+		"expiresAt":            r.ExpiresAt,
+	}
+}
+
+func (r SecretRollbackRequest) AuditFields() map[string]string {
+	return map[string]string{
+		data.AuditFieldErr: r.Err,
+		"workloadId":       r.WorkloadId,
+		"version":          strconv.FormatInt(r.Version, 10),
+	}
+}
+
+func (r SecretRollbackResponse) AuditFields() map[string]string {
+	return map[string]string{
+		data.AuditFieldErr: r.Err,
+		"version":          strconv.FormatInt(r.Version, 10),
+	}
+}
+
+func (r SecretListRequest) AuditFields() map[string]string {
+	return map[string]string{
+		data.AuditFieldErr: r.Err,
+		"selector":         r.Selector,
+	}
+}
+
+func (r SecretListResponse) AuditFields() map[string]string {
+	return map[string]string{data.AuditFieldErr: r.Err}
+}
+
+func (r GenericRequest) AuditFields() map[string]string {
+	return map[string]string{data.AuditFieldErr: r.Err}
+}
+
+func (r GenericResponse) AuditFields() map[string]string {
+	return map[string]string{data.AuditFieldErr: r.Err}
+}
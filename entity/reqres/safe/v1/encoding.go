@@ -0,0 +1,44 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+)
+
+// Binary secrets travel as base64 text, so that they survive JSON intact:
+//
+//	req.SetBytes(keytab)              // client
+//	b, err := req.Bytes()             // Safe; validates the value
+//	stored.UpdateBytes(b, n, now)     // Safe
+//	b, err = stored.Bytes()           // Safe, on fetch
+//	res.SetBytes(b)                   // Safe
+//	keytab, err := res.Bytes()        // sidecar
+
+// SetBytes sets the value to b, base64-encoding it if it is binary.
+func (r *SecretUpsertRequest) SetBytes(b []byte) {
+	r.Value, r.Encoding = data.EncodeValue(b)
+}
+
+// Bytes returns the bytes of the value; it returns an error if the value
+// does not match its encoding.
+func (r SecretUpsertRequest) Bytes() ([]byte, error) {
+	return data.DecodeValue(r.Value, r.Encoding)
+}
+
+// SetBytes sets the data to b, base64-encoding it if it is binary.
+func (r *SecretFetchResponse) SetBytes(b []byte) {
+	r.Data, r.Encoding = data.EncodeValue(b)
+}
+
+// Bytes returns the bytes of the data; it returns an error if the data
+// does not match its encoding.
+func (r SecretFetchResponse) Bytes() ([]byte, error) {
+	return data.DecodeValue(r.Data, r.Encoding)
+}
@@ -0,0 +1,117 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"github.com/zerotohero-dev/aegis-core/transform"
+	"testing"
+	"time"
+)
+
+// overJson sends v through JSON into out, as the requests and responses
+// travel between the client, Safe, and the sidecar.
+func overJson(t *testing.T, v, out any) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	// Not valid UTF-8; JSON would replace the invalid bytes with U+FFFD.
+	keytab := []byte{0x05, 0x02, 0x00, 0xff, 0xfe, 'k', 0xc3, 0x28, '\n', 0x80}
+
+	var req SecretUpsertRequest
+	req.SetBytes(keytab)
+	if req.Encoding != data.Base64 {
+		t.Fatalf("want a base64 encoding, got %q", req.Encoding)
+	}
+	var received SecretUpsertRequest
+	overJson(t, req, &received)
+
+	b, err := received.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := data.SecretStored{Name: "keytab"}
+	stored.UpdateBytes(b, 3, time.Now())
+	if err := transform.Apply(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = stored.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res SecretFetchResponse
+	res.SetBytes(b)
+	var fetched SecretFetchResponse
+	overJson(t, res, &fetched)
+
+	got, err := fetched.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, keytab) {
+		t.Errorf("got %x, want %x", got, keytab)
+	}
+	// Workloads see the transformed value; it must be the same bytes.
+	got, err = data.DecodeValue(stored.ValueTransformed, stored.Encoding)
+	if err != nil || !bytes.Equal(got, keytab) {
+		t.Errorf("got the transformed value %x, want %x", got, keytab)
+	}
+}
+
+func TestBinaryValuesCannotBeTransformed(t *testing.T) {
+	keytab := []byte{0xff, 0xfe, '{', '{', '.', '}', '}'}
+
+	tests := []struct {
+		name  string
+		meta  data.SecretMeta
+		stage transform.Stage
+	}{
+		{"template", data.SecretMeta{Template: `{{.}}`}, transform.StageTemplate},
+		{"format", data.SecretMeta{Format: data.Json}, transform.StageFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := data.SecretStored{Name: "keytab", Meta: tt.meta}
+			stored.UpdateBytes(keytab, 3, time.Now())
+
+			err := transform.Apply(&stored)
+			var te *transform.Error
+			if !errors.As(err, &te) || te.Stage != tt.stage {
+				t.Fatalf("want a %s *transform.Error, got %v", tt.stage, err)
+			}
+			if stored.ValueTransformed != "" {
+				t.Errorf("want no transformed value, got %q", stored.ValueTransformed)
+			}
+		})
+	}
+}
+
+func TestBytesRejectsMismatchedEncodings(t *testing.T) {
+	req := SecretUpsertRequest{Value: "not base64!", Encoding: data.Base64}
+	if _, err := req.Bytes(); err == nil {
+		t.Error("want invalid base64 to be an error")
+	}
+	res := SecretFetchResponse{Data: "a", Encoding: "hex"}
+	if _, err := res.Bytes(); err == nil {
+		t.Error("want an unknown encoding to be an error")
+	}
+}
@@ -0,0 +1,29 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"time"
+)
+
+// SetValidity sets NotBefore and ExpiresAt to the validity period of s, as
+// RFC 3339 times in UTC; see data.SecretStored.ExpiresAt. Times that s does
+// not have are left empty.
+func (r *SecretFetchResponse) SetValidity(s data.SecretStored) {
+	r.NotBefore = formatTime(s.Meta.NotBefore)
+	r.ExpiresAt = formatTime(s.ExpiresAt())
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
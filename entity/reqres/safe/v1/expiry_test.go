@@ -0,0 +1,65 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v1
+
+import (
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"testing"
+	"time"
+)
+
+func TestSetValidity(t *testing.T) {
+	updated := time.Date(2023, 4, 1, 10, 20, 30, 500, time.FixedZone("", 3600))
+
+	tests := []struct {
+		name      string
+		meta      data.SecretMeta
+		notBefore string
+		expiresAt string
+	}{
+		{"no validity period", data.SecretMeta{}, "", ""},
+		{
+			"ttl",
+			data.SecretMeta{Ttl: 72 * time.Hour},
+			"", "2023-04-04T09:20:30.0000005Z",
+		},
+		{
+			"expiresAt",
+			data.SecretMeta{
+				NotBefore: updated.Add(-time.Hour),
+				ExpiresAt: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+				Ttl:       time.Hour,
+			},
+			"2023-04-01T08:20:30.0000005Z", "2023-05-01T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res SecretFetchResponse
+			res.SetValidity(data.SecretStored{Updated: updated, Meta: tt.meta})
+			if res.NotBefore != tt.notBefore || res.ExpiresAt != tt.expiresAt {
+				t.Errorf("got %q, %q; want %q, %q",
+					res.NotBefore, res.ExpiresAt, tt.notBefore, tt.expiresAt)
+			}
+
+			// The sidecars read the times back exactly.
+			if res.ExpiresAt != "" {
+				exp, err := time.Parse(time.RFC3339, res.ExpiresAt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := data.SecretStored{Updated: updated, Meta: tt.meta}.ExpiresAt()
+				if !exp.Equal(want) {
+					t.Errorf("got %v, want %v", exp, want)
+				}
+			}
+		})
+	}
+}
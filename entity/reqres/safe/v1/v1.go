@@ -17,11 +17,27 @@ type SecretUpsertRequest struct {
 	BackingStore  data.BackingStore `json:"backingStore"`
 	UseKubernetes bool              `json:"useKubernetes"`
 	Namespace     string            `json:"namespace"`
-	Value         string            `json:"value"`
-	Template      string            `json:"template"`
+	Value         string            `json:"value" aegis:"secret"`
+	Template      string            `json:"template" aegis:"secret"`
 	Format        data.SecretFormat `json:"format"`
 	Encrypt       bool              `json:"bool"`
-	Err           string            `json:"err,omitempty"`
+	// Encoding of Value; see SetBytes. Defaults to data.Utf8.
+	Encoding data.ValueEncoding `json:"encoding,omitempty"`
+	// Keys and values of a structured secret; if set, Value is ignored.
+	// See data.SecretStored.Values.
+	Values map[string]string `json:"values,omitempty" aegis:"secret"`
+	// If set, only the value with this key is set to Value, and the other
+	// values of the secret are kept; see data.SecretStored.UpdateKey.
+	ValueKey string `json:"valueKey,omitempty"`
+	// RFC 3339 time; see data.SecretMeta.NotBefore.
+	NotBefore string `json:"notBefore,omitempty"`
+	// RFC 3339 time; see data.SecretMeta.ExpiresAt.
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	// Duration, such as "72h"; see data.SecretMeta.Ttl.
+	Ttl         string            `json:"ttl,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Err         string            `json:"err,omitempty"`
 }
 
 type SecretUpsertResponse struct {
@@ -29,18 +45,46 @@ type SecretUpsertResponse struct {
 }
 
 type SecretFetchRequest struct {
-	Err string `json:"err,omitempty"`
+	// Version to fetch; 0 fetches the current version.
+	Version int64 `json:"version,omitempty"`
+	// If set, only the value with this key is fetched, untransformed.
+	ValueKey string `json:"valueKey,omitempty"`
+	Err      string `json:"err,omitempty"`
 }
 
 type SecretFetchResponse struct {
-	Data    string `json:"data"`
+	Data    string `json:"data" aegis:"secret"`
+	Version int64  `json:"version,omitempty"`
 	Created string `json:"created"`
 	Updated string `json:"updated"`
+	// Encoding of Data; see Bytes. Defaults to data.Utf8.
+	Encoding data.ValueEncoding `json:"encoding,omitempty"`
+	// Keys of the values of a structured secret, sorted.
+	Keys []string `json:"keys,omitempty"`
+	// Validity period of the secret, if it has one, so that the sidecars
+	// can alert before the secret lapses; see SetValidity.
+	NotBefore string `json:"notBefore,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	Err       string `json:"err,omitempty"`
+}
+
+type SecretRollbackRequest struct {
+	WorkloadId string `json:"key"`
+	Version    int64  `json:"version"`
+	Err        string `json:"err,omitempty"`
+}
+
+type SecretRollbackResponse struct {
+	// The version that the rollback has created.
+	Version int64  `json:"version"`
 	Err     string `json:"err,omitempty"`
 }
 
 type SecretListRequest struct {
-	Err string `json:"err,omitempty"`
+	// Label selector, such as `env=prod,team in (a,b)`, to list only the
+	// matching secrets; see selector.Parse. Empty lists all secrets.
+	Selector string `json:"selector,omitempty"`
+	Err      string `json:"err,omitempty"`
 }
 
 type SecretListResponse struct {
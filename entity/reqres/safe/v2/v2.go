@@ -0,0 +1,27 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package v2
+
+import (
+	v1 "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v2"
+)
+
+// The v2 API differs from the v1 API only in its time format; the request
+// and response types that carry no times are the v1 types.
+
+type SecretListResponse struct {
+	Secrets []data.Secret `json:"secrets"`
+	Err     string        `json:"err,omitempty"`
+}
+
+// AuditFields implements audit.Auditable.
+func (r SecretListResponse) AuditFields() map[string]string {
+	return map[string]string{v1.AuditFieldErr: r.Err}
+}
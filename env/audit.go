@@ -0,0 +1,233 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditChainCheckpointInterval returns the number of records that the
+// hash-chained audit journal writes between two checkpoints. The value is
+// read from the environment variable `AEGIS_AUDIT_CHAIN_CHECKPOINT_INTERVAL`
+// or returns 1000 as default. A value less than 1 disables periodic
+// checkpoints; a checkpoint is still written when the journal is closed.
+func AuditChainCheckpointInterval() int {
+	p := os.Getenv("AEGIS_AUDIT_CHAIN_CHECKPOINT_INTERVAL")
+	if p == "" {
+		return 1000
+	}
+	i, err := strconv.Atoi(p)
+	if err != nil {
+		return 1000
+	}
+	return i
+}
+
+// AuditQueueSize returns the capacity of the queue of the asynchronous audit
+// pipeline. The value is read from the environment variable
+// `AEGIS_AUDIT_QUEUE_SIZE` or returns 1000 as default.
+func AuditQueueSize() int {
+	p := os.Getenv("AEGIS_AUDIT_QUEUE_SIZE")
+	if p == "" {
+		return 1000
+	}
+	i, err := strconv.Atoi(p)
+	if err != nil || i < 1 {
+		return 1000
+	}
+	return i
+}
+
+// AuditWorkerCount returns the number of goroutines that deliver the records
+// of the asynchronous audit pipeline to the sinks. The value is read from the
+// environment variable `AEGIS_AUDIT_WORKER_COUNT` or returns 1 as default.
+// With a single worker, the records reach the sinks in the order they are
+// logged.
+func AuditWorkerCount() int {
+	p := os.Getenv("AEGIS_AUDIT_WORKER_COUNT")
+	if p == "" {
+		return 1
+	}
+	i, err := strconv.Atoi(p)
+	if err != nil || i < 1 {
+		return 1
+	}
+	return i
+}
+
+// AuditDropWhenQueueFull returns a boolean indicating whether the
+// asynchronous audit pipeline drops new records when its queue is full,
+// instead of blocking the caller until there is room in the queue.
+//
+// If the environment variable `AEGIS_AUDIT_DROP_WHEN_QUEUE_FULL` is not set or
+// its value is not "true", the function returns false. Otherwise, the
+// function returns true.
+func AuditDropWhenQueueFull() bool {
+	p := os.Getenv("AEGIS_AUDIT_DROP_WHEN_QUEUE_FULL")
+	if p == "" {
+		return false
+	}
+	if strings.ToLower(p) == "true" {
+		return true
+	}
+	return false
+}
+
+// AuditAnomalyWindow returns the length of the sliding window in which the
+// audit anomaly detector counts the authorization failures of a peer.
+// The window is specified in milliseconds as the
+// `AEGIS_AUDIT_ANOMALY_WINDOW` environment variable. If the environment
+// variable is not set or is not a valid integer value, the function returns
+// the default window of 60000 milliseconds.
+func AuditAnomalyWindow() time.Duration {
+	p := os.Getenv("AEGIS_AUDIT_ANOMALY_WINDOW")
+	if p == "" {
+		p = "60000"
+	}
+	i, err := strconv.ParseInt(p, 10, 32)
+	if err != nil {
+		return 60000 * time.Millisecond
+	}
+	return time.Duration(i) * time.Millisecond
+}
+
+// AuditAnomalyThreshold returns the number of authorization failures of a
+// peer, within the window of AuditAnomalyWindow, that raises an anomaly.
+// The value is read from the environment variable
+// `AEGIS_AUDIT_ANOMALY_THRESHOLD` or returns 10 as default.
+func AuditAnomalyThreshold() int {
+	p := os.Getenv("AEGIS_AUDIT_ANOMALY_THRESHOLD")
+	if p == "" {
+		return 10
+	}
+	i, err := strconv.Atoi(p)
+	if err != nil || i < 1 {
+		return 10
+	}
+	return i
+}
+
+// AuditAnomalyDenyDuration returns how long a peer that raises an anomaly
+// stays denied. The duration is specified in milliseconds as the
+// `AEGIS_AUDIT_ANOMALY_DENY_DURATION` environment variable. If the
+// environment variable is not set or is not a valid integer value, the
+// function returns the default duration of 300000 milliseconds.
+func AuditAnomalyDenyDuration() time.Duration {
+	p := os.Getenv("AEGIS_AUDIT_ANOMALY_DENY_DURATION")
+	if p == "" {
+		p = "300000"
+	}
+	i, err := strconv.ParseInt(p, 10, 32)
+	if err != nil {
+		return 300000 * time.Millisecond
+	}
+	return time.Duration(i) * time.Millisecond
+}
+
+// AuditJournalPath returns the path of the rotating audit journal file.
+// The path is determined by the `AEGIS_AUDIT_JOURNAL_PATH` environment
+// variable. If the environment variable is not set, the default path is
+// "audit/journal.log" under SafeDataPath.
+func AuditJournalPath() string {
+	p := os.Getenv("AEGIS_AUDIT_JOURNAL_PATH")
+	if p == "" {
+		p = filepath.Join(SafeDataPath(), "audit", "journal.log")
+	}
+	return p
+}
+
+// AuditJournalMaxSize returns the size, in bytes, that the audit journal
+// file is allowed to grow to before it is rotated. The value is read from
+// the environment variable `AEGIS_AUDIT_JOURNAL_MAX_SIZE` or returns
+// 104857600 (100 MiB) as default. A value less than 1 disables size-based
+// rotation.
+func AuditJournalMaxSize() int64 {
+	p := os.Getenv("AEGIS_AUDIT_JOURNAL_MAX_SIZE")
+	if p == "" {
+		return 104857600
+	}
+	i, err := strconv.ParseInt(p, 10, 64)
+	if err != nil {
+		return 104857600
+	}
+	return i
+}
+
+// AuditJournalRotateInterval returns how long the audit journal file is
+// written to before it is rotated. The interval is specified in milliseconds
+// as the `AEGIS_AUDIT_JOURNAL_ROTATE_INTERVAL` environment variable. If the
+// environment variable is not set or is not a valid integer value, the
+// function returns the default interval of 86400000 milliseconds (one day).
+// A value less than 1 disables time-based rotation.
+func AuditJournalRotateInterval() time.Duration {
+	p := os.Getenv("AEGIS_AUDIT_JOURNAL_ROTATE_INTERVAL")
+	if p == "" {
+		p = "86400000"
+	}
+	i, err := strconv.ParseInt(p, 10, 64)
+	if err != nil {
+		return 86400000 * time.Millisecond
+	}
+	return time.Duration(i) * time.Millisecond
+}
+
+// AuditJournalMaxAge returns how long the rotated audit journal files are
+// retained. The duration is specified in milliseconds as the
+// `AEGIS_AUDIT_JOURNAL_MAX_AGE` environment variable. If the environment
+// variable is not set or is not a valid integer value, the function returns
+// the default duration of 2592000000 milliseconds (30 days). A value less
+// than 1 disables age-based retention.
+func AuditJournalMaxAge() time.Duration {
+	p := os.Getenv("AEGIS_AUDIT_JOURNAL_MAX_AGE")
+	if p == "" {
+		p = "2592000000"
+	}
+	i, err := strconv.ParseInt(p, 10, 64)
+	if err != nil {
+		return 2592000000 * time.Millisecond
+	}
+	return time.Duration(i) * time.Millisecond
+}
+
+// AuditJournalMaxFiles returns the number of rotated audit journal files to
+// retain. The value is read from the environment variable
+// `AEGIS_AUDIT_JOURNAL_MAX_FILES` or returns 10 as default. A value less than
+// 1 disables count-based retention.
+func AuditJournalMaxFiles() int {
+	p := os.Getenv("AEGIS_AUDIT_JOURNAL_MAX_FILES")
+	if p == "" {
+		return 10
+	}
+	i, err := strconv.Atoi(p)
+	if err != nil {
+		return 10
+	}
+	return i
+}
+
+// AuditJournalCompress returns a boolean indicating whether the rotated audit
+// journal files are gzip-compressed.
+//
+// If the environment variable `AEGIS_AUDIT_JOURNAL_COMPRESS` is not set or its
+// value is not "true", the function returns false. Otherwise, the function
+// returns true.
+func AuditJournalCompress() bool {
+	p := os.Getenv("AEGIS_AUDIT_JOURNAL_COMPRESS")
+	if p == "" {
+		return false
+	}
+	if strings.ToLower(p) == "true" {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package env
+
+import "os"
+
+// OtlpLogsEndpointUrl returns the URL of the OTLP/HTTP endpoint that the logs
+// and the audit records are exported to. The URL is obtained from the
+// standard OpenTelemetry environment variable
+// OTEL_EXPORTER_OTLP_LOGS_ENDPOINT. If the variable is not set, the default
+// URL of a collector running as a sidecar is used.
+func OtlpLogsEndpointUrl() string {
+	u := os.Getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT")
+	if u == "" {
+		u = "http://localhost:4318/v1/logs"
+	}
+	return u
+}
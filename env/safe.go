@@ -127,6 +127,38 @@ func SafeAgeKeyPath() string {
 	return p
 }
 
+// SafeAuditSigningKeyPath returns the path to the private key that Aegis Safe
+// uses to sign its audit records.
+// The path is determined by the AEGIS_SAFE_AUDIT_SIGNING_KEY_PATH environment
+// variable. If the environment variable is not set, the default path
+// "/key/audit-signing-key.pem" is returned.
+//
+// The key is an ed25519 private key in PKCS #8, PEM-encoded format, as
+// `openssl genpkey -algorithm ed25519` outputs.
+func SafeAuditSigningKeyPath() string {
+	p := os.Getenv("AEGIS_SAFE_AUDIT_SIGNING_KEY_PATH")
+	if p == "" {
+		p = "/key/audit-signing-key.pem"
+	}
+	return p
+}
+
+// SafeAuditVerificationKeysPath returns the path to the directory that holds
+// the public keys to verify the signed audit records with.
+// The path is determined by the AEGIS_SAFE_AUDIT_VERIFICATION_KEYS_PATH
+// environment variable. If the environment variable is not set, the default
+// path "/key/audit-verification-keys" is returned.
+//
+// Keep the public keys of the retired signing keys in this directory, so that
+// the records they signed remain verifiable after a key rotation.
+func SafeAuditVerificationKeysPath() string {
+	p := os.Getenv("AEGIS_SAFE_AUDIT_VERIFICATION_KEYS_PATH")
+	if p == "" {
+		p = "/key/audit-verification-keys"
+	}
+	return p
+}
+
 // SafeSvidRetrievalTimeout returns the allowed time for Aegis Safe to wait
 // before killing the pod to retrieve an SVID, in time.Duration.
 // The interval is determined by the AEGIS_SAFE_SVID_RETRIEVAL_TIMEOUT environment
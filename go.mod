@@ -1,3 +1,10 @@
 module github.com/zerotohero-dev/aegis-core
 
 go 1.20
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/joho/godotenv v1.5.1
+	github.com/magiconair/properties v1.8.7
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -10,6 +10,7 @@ package log
 
 import (
 	"github.com/zerotohero-dev/aegis-core/env"
+	"github.com/zerotohero-dev/aegis-core/redact"
 	"log"
 	"sync"
 )
@@ -26,6 +27,51 @@ const Trace Level = 6
 var currentLevel = Level(env.LogLevel())
 var mux sync.Mutex
 
+// Backend writes the log lines that pass the level check. The arguments are
+// redacted before they reach the backend.
+type Backend interface {
+	Log(l Level, v ...any)
+}
+
+// Flusher is implemented by the backends that buffer log lines. FatalLn
+// flushes the backend before it exits the process, so that the last lines,
+// which tell why the process exited, are not lost.
+type Flusher interface {
+	Flush() error
+}
+
+// CorrelationId marks a log argument as the correlation id of the request
+// that the line is about, so that the backends can tie the line to the
+// request:
+//
+//	log.InfoLn(log.CorrelationId(id), "secret created")
+//
+// The standard logger prints it as is.
+type CorrelationId string
+
+// backend is nil when the lines are written to the standard logger.
+var backend Backend
+
+// SetBackend makes the package write its log lines to b, instead of the
+// standard logger. Passing nil restores the standard logger.
+func SetBackend(b Backend) {
+	mux.Lock()
+	defer mux.Unlock()
+	backend = b
+}
+
+func emit(l Level, v []any) {
+	mux.Lock()
+	b := backend
+	mux.Unlock()
+
+	if b == nil {
+		log.Println(v...)
+		return
+	}
+	b.Log(l, v...)
+}
+
 func SetLevel(l Level) {
 	mux.Lock()
 	defer mux.Unlock()
@@ -41,8 +87,30 @@ func GetLevel(v ...any) Level {
 	return currentLevel
 }
 
+// redacted returns a copy of v where the secret fields of every value are
+// redacted; see redact.Redact.
+func redacted(v []any) []any {
+	r := make([]any, len(v))
+	for i, a := range v {
+		r[i] = redact.Redact(a)
+	}
+	return r
+}
+
 func FatalLn(v ...any) {
-	log.Fatalln(v...)
+	r := redacted(v)
+	mux.Lock()
+	b := backend
+	mux.Unlock()
+	if b != nil {
+		b.Log(Error, r...)
+		if f, ok := b.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				log.Println("log: failed to flush the backend:", err.Error())
+			}
+		}
+	}
+	log.Fatalln(r...)
 }
 
 func ErrorLn(v ...any) {
@@ -50,7 +118,7 @@ func ErrorLn(v ...any) {
 	if l < Error {
 		return
 	}
-	log.Println(v...)
+	emit(Error, redacted(v))
 }
 
 func WarnLn(v ...any) {
@@ -58,7 +126,7 @@ func WarnLn(v ...any) {
 	if l < Warn {
 		return
 	}
-	log.Println(v...)
+	emit(Warn, redacted(v))
 }
 
 func InfoLn(v ...any) {
@@ -66,7 +134,7 @@ func InfoLn(v ...any) {
 	if l < Info {
 		return
 	}
-	log.Println(v...)
+	emit(Info, redacted(v))
 }
 
 func DebugLn(v ...any) {
@@ -74,7 +142,7 @@ func DebugLn(v ...any) {
 	if l < Debug {
 		return
 	}
-	log.Println(v...)
+	emit(Debug, redacted(v))
 }
 
 func TraceLn(v ...any) {
@@ -82,5 +150,5 @@ func TraceLn(v ...any) {
 	if l < Trace {
 		return
 	}
-	log.Println(v...)
+	emit(Trace, redacted(v))
 }
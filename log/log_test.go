@@ -0,0 +1,108 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const secret = "hunter2"
+
+type credentials struct {
+	User     string
+	Password string `aegis:"secret"`
+}
+
+type request struct {
+	Name  string
+	creds *credentials
+	Any   any
+}
+
+type recorder struct {
+	mux   sync.Mutex
+	lines []string
+}
+
+func (r *recorder) Log(l Level, v ...any) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf("%d %+v", l, v))
+}
+
+func values() []any {
+	c := credentials{User: "admin", Password: secret}
+	return []any{
+		c,
+		&c,
+		[]credentials{c},
+		map[string]any{"creds": c},
+		request{Name: "example", creds: &c, Any: c},
+	}
+}
+
+func TestBackendReceivesRedactedValues(t *testing.T) {
+	SetLevel(Trace)
+	r := &recorder{}
+	SetBackend(r)
+	defer SetBackend(nil)
+
+	for _, f := range []func(...any){ErrorLn, WarnLn, InfoLn, DebugLn, TraceLn} {
+		f(values()...)
+	}
+
+	if len(r.lines) != 5 {
+		t.Fatalf("got %d lines, want 5", len(r.lines))
+	}
+	for _, l := range r.lines {
+		if strings.Contains(l, secret) {
+			t.Errorf("the backend has received the secret: %s", l)
+		}
+		if !strings.Contains(l, "admin") {
+			t.Errorf("non-secret fields are missing: %s", l)
+		}
+	}
+}
+
+func TestStandardLoggerReceivesRedactedValues(t *testing.T) {
+	SetLevel(Trace)
+	var b bytes.Buffer
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stderr)
+
+	InfoLn(values()...)
+
+	if b.Len() == 0 {
+		t.Fatal("nothing was logged")
+	}
+	if strings.Contains(b.String(), secret) {
+		t.Errorf("the logger has received the secret: %s", b.String())
+	}
+}
+
+func TestLevels(t *testing.T) {
+	r := &recorder{}
+	SetBackend(r)
+	defer SetBackend(nil)
+
+	SetLevel(Warn)
+	defer SetLevel(Trace)
+	InfoLn("dropped")
+	WarnLn("kept")
+
+	if len(r.lines) != 1 || !strings.Contains(r.lines[0], "kept") {
+		t.Errorf("got %v, want only the warning", r.lines)
+	}
+}
@@ -0,0 +1,386 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"github.com/zerotohero-dev/aegis-core/env"
+	"github.com/zerotohero-dev/aegis-core/log"
+	"io"
+	stdlog "log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const scopeAudit = "github.com/zerotohero-dev/aegis-core/audit"
+const scopeLog = "github.com/zerotohero-dev/aegis-core/log"
+
+// Config configures an Exporter.
+type Config struct {
+	// URL of the OTLP/HTTP logs endpoint. Defaults to
+	// env.OtlpLogsEndpointUrl().
+	Endpoint string
+	// Value of the `service.name` resource attribute. Defaults to
+	// "aegis-safe".
+	ServiceName string
+	// Additional headers to send, such as authorization headers.
+	Headers map[string]string
+	// Defaults to an http.Client with a 5-second timeout.
+	Client *http.Client
+	// Number of records to buffer before exporting them. Defaults to 100.
+	BatchSize int
+	// Number of records to keep while the collector is unavailable; the
+	// records that failed to export are retried with the next flush.
+	// Beyond this many, the oldest records are dropped. Defaults to
+	// 10 times BatchSize.
+	MaxBuffered int
+	// Buffered records are exported at least this often. Defaults to
+	// 5 seconds.
+	FlushInterval time.Duration
+}
+
+// Exporter exports records to an OpenTelemetry collector, over OTLP/HTTP,
+// with the JSON encoding.
+//
+// An Exporter is both an audit.Sink and a log.Backend:
+//
+//	x := otlp.NewExporter(otlp.Config{})
+//	audit.RegisterSink("otlp", x)
+//	log.SetBackend(x)
+//
+// Audit records, and the log lines that have a log.CorrelationId argument,
+// carry their correlation id as the `aegis.correlation_id` attribute, and as
+// a trace id derived from it (see TraceId). Log levels map to the OTel
+// severity numbers. The Exporter implements log.Flusher, so that log.FatalLn
+// exports the buffered records before the process exits.
+type Exporter struct {
+	config Config
+
+	mux    sync.Mutex
+	buffer []LogRecord
+	closed bool
+
+	sendMux sync.Mutex
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewExporter creates an Exporter, and starts its periodic flush.
+func NewExporter(c Config) *Exporter {
+	if c.Endpoint == "" {
+		c.Endpoint = env.OtlpLogsEndpointUrl()
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = "aegis-safe"
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	if c.BatchSize < 1 {
+		c.BatchSize = 100
+	}
+	if c.MaxBuffered < c.BatchSize {
+		c.MaxBuffered = 10 * c.BatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 5 * time.Second
+	}
+
+	x := &Exporter{config: c, done: make(chan struct{})}
+	x.wg.Add(1)
+	go func() {
+		defer x.wg.Done()
+		ticker := time.NewTicker(c.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-x.done:
+				return
+			case <-ticker.C:
+				if err := x.Flush(); err != nil {
+					report(err)
+				}
+			}
+		}
+	}()
+	return x
+}
+
+// Write implements audit.Sink. If the write fills a batch, the buffered
+// records are exported before Write returns. If the export fails, the
+// records stay in the buffer, and are retried with the next flush.
+func (x *Exporter) Write(e audit.JournalEntry) error {
+	r := audit.NewRecord(e)
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	severity := SeverityWarn
+	switch r.Event {
+	case audit.EventOk, audit.EventEnter:
+		severity = SeverityInfo
+	case audit.EventExit:
+		if r.Status < 400 {
+			severity = SeverityInfo
+		}
+	}
+
+	attrs := []KeyValue{
+		attr("aegis.correlation_id", r.CorrelationId),
+		attr("aegis.svid", r.Svid),
+		attr("aegis.event", string(r.Event)),
+		attr("http.method", r.Method),
+		attr("http.url", r.Url),
+	}
+	if r.Status != 0 {
+		attrs = append(attrs, attr("http.status_code", strconv.Itoa(r.Status)))
+	}
+	if r.Error != "" {
+		attrs = append(attrs, attr("aegis.error", r.Error))
+	}
+	keys := make([]string, 0, len(r.Fields))
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, attr("aegis."+k, r.Fields[k]))
+	}
+
+	now := time.Now()
+	t := r.Timestamp
+	if t.IsZero() {
+		t = now
+	}
+
+	return x.add(LogRecord{
+		TimeUnixNano:         unixNano(t),
+		ObservedTimeUnixNano: unixNano(now),
+		SeverityNumber:       severity,
+		SeverityText:         severity.String(),
+		Body:                 AnyValue{StringValue: string(body)},
+		Attributes:           attrs,
+		TraceId:              TraceId(r.CorrelationId),
+		scope:                scopeAudit,
+	})
+}
+
+// Log implements log.Backend. Export failures are reported to the standard
+// error, since reporting them through the `log` package would loop back to
+// the exporter.
+func (x *Exporter) Log(l log.Level, v ...any) {
+	severity := SeverityTrace
+	switch l {
+	case log.Error:
+		severity = SeverityError
+	case log.Warn:
+		severity = SeverityWarn
+	case log.Info:
+		severity = SeverityInfo
+	case log.Debug:
+		severity = SeverityDebug
+	}
+
+	// The correlation id goes to the attributes and the trace id, instead
+	// of the body.
+	var correlationId string
+	args := make([]any, 0, len(v))
+	for _, a := range v {
+		if id, ok := a.(log.CorrelationId); ok {
+			if correlationId == "" {
+				correlationId = string(id)
+			}
+			continue
+		}
+		args = append(args, a)
+	}
+	var attrs []KeyValue
+	if correlationId != "" {
+		attrs = []KeyValue{attr("aegis.correlation_id", correlationId)}
+	}
+
+	now := unixNano(time.Now())
+	err := x.add(LogRecord{
+		TimeUnixNano:         now,
+		ObservedTimeUnixNano: now,
+		SeverityNumber:       severity,
+		SeverityText:         severity.String(),
+		Body: AnyValue{
+			StringValue: strings.TrimSuffix(fmt.Sprintln(args...), "\n"),
+		},
+		Attributes: attrs,
+		TraceId:    TraceId(correlationId),
+		scope:      scopeLog,
+	})
+	if err != nil {
+		report(err)
+	}
+}
+
+// Flush exports the buffered records. If the export fails, the records are
+// kept in the buffer, and retried with the next flush.
+func (x *Exporter) Flush() error {
+	// Take the records while holding sendMux, so that the records of
+	// concurrent flushes are sent in order.
+	x.sendMux.Lock()
+	defer x.sendMux.Unlock()
+
+	x.mux.Lock()
+	records := x.buffer
+	x.buffer = nil
+	x.mux.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+	err := x.send(records)
+	if err != nil {
+		x.mux.Lock()
+		// The records that were added during the export are newer.
+		x.buffer = append(records, x.buffer...)
+		dropped := x.trim()
+		x.mux.Unlock()
+		reportDropped(dropped)
+	}
+	return err
+}
+
+// Close stops the periodic flush, and exports the buffered records. If the
+// export fails, the records are lost.
+func (x *Exporter) Close() error {
+	x.mux.Lock()
+	if x.closed {
+		x.mux.Unlock()
+		return nil
+	}
+	x.closed = true
+	x.mux.Unlock()
+
+	close(x.done)
+	x.wg.Wait()
+	err := x.Flush()
+	x.config.Client.CloseIdleConnections()
+	return err
+}
+
+// Export sends records to the collector in a single request. The records
+// are not buffered, nor retried.
+func (x *Exporter) Export(records []LogRecord) error {
+	x.sendMux.Lock()
+	defer x.sendMux.Unlock()
+	return x.send(records)
+}
+
+// send sends records to the collector. Must be called with sendMux held.
+func (x *Exporter) send(records []LogRecord) error {
+	body, err := json.Marshal(x.payload(records))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPost, x.config.Endpoint, bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range x.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := x.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("otlp: collector responded with %s", res.Status)
+	}
+	return nil
+}
+
+// add buffers r, and exports the buffered records every BatchSize records.
+// A failed export is reported, rather than returned, since r is kept in the
+// buffer, to be retried.
+func (x *Exporter) add(r LogRecord) error {
+	x.mux.Lock()
+	if x.closed {
+		x.mux.Unlock()
+		return fmt.Errorf("otlp: exporter is closed")
+	}
+	x.buffer = append(x.buffer, r)
+	dropped := x.trim()
+	full := len(x.buffer)%x.config.BatchSize == 0
+	x.mux.Unlock()
+
+	reportDropped(dropped)
+	if !full {
+		return nil
+	}
+	if err := x.Flush(); err != nil {
+		report(err)
+	}
+	return nil
+}
+
+// trim drops the oldest records beyond MaxBuffered, and returns their
+// number. Must be called with mux held.
+func (x *Exporter) trim() int {
+	n := len(x.buffer) - x.config.MaxBuffered
+	if n <= 0 {
+		return 0
+	}
+	x.buffer = append([]LogRecord(nil), x.buffer[n:]...)
+	return n
+}
+
+// payload groups records by their instrumentation scope.
+func (x *Exporter) payload(records []LogRecord) logsData {
+	var scopes []scopeLogs
+	index := map[string]int{}
+	for _, r := range records {
+		i, ok := index[r.scope]
+		if !ok {
+			i = len(scopes)
+			index[r.scope] = i
+			scopes = append(scopes, scopeLogs{Scope: scope{Name: r.scope}})
+		}
+		scopes[i].LogRecords = append(scopes[i].LogRecords, r)
+	}
+
+	return logsData{ResourceLogs: []resourceLogs{{
+		Resource: resource{Attributes: []KeyValue{
+			attr("service.name", x.config.ServiceName),
+		}},
+		ScopeLogs: scopes,
+	}}}
+}
+
+func report(err error) {
+	stdlog.Println("otlp: export failed:", err.Error())
+}
+
+func reportDropped(n int) {
+	if n > 0 {
+		stdlog.Println("otlp: buffer is full, dropped", n, "records")
+	}
+}
@@ -0,0 +1,349 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zerotohero-dev/aegis-core/audit"
+	"github.com/zerotohero-dev/aegis-core/log"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a stand-in for an OTLP/HTTP collector; it records the
+// requests that it receives.
+type collector struct {
+	mux      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+	// Number of requests to fail with 503, before accepting any.
+	failures int
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	c.requests = append(c.requests, r)
+	c.bodies = append(c.bodies, body)
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+}
+
+// logBodies returns the bodies of the log records that the collector has
+// accepted, in order.
+func (c *collector) logBodies(t *testing.T) string {
+	t.Helper()
+	var bodies []string
+	for _, p := range c.payloads(t) {
+		for _, r := range p.ResourceLogs[0].ScopeLogs[0].LogRecords {
+			bodies = append(bodies, r.Body.StringValue)
+		}
+	}
+	return strings.Join(bodies, ",")
+}
+
+// payloads decodes the bodies of the received requests.
+func (c *collector) payloads(t *testing.T) []logsData {
+	t.Helper()
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var payloads []logsData
+	for _, b := range c.bodies {
+		var p logsData
+		if err := json.Unmarshal(b, &p); err != nil {
+			t.Fatalf("the payload is not OTLP/JSON: %s: %s", err.Error(), b)
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{}
+	s := httptest.NewServer(c)
+	t.Cleanup(s.Close)
+	return c, s
+}
+
+func attrs(r LogRecord) map[string]string {
+	m := map[string]string{}
+	for _, a := range r.Attributes {
+		m[a.Key] = a.Value.StringValue
+	}
+	return m
+}
+
+func TestExportPayload(t *testing.T) {
+	c, s := newCollector(t)
+	x := NewExporter(Config{
+		Endpoint:      s.URL,
+		ServiceName:   "aegis-test",
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+
+	x.Write(audit.JournalEntry{
+		CorrelationId: "cid-1",
+		Method:        http.MethodPost,
+		Url:           "/secrets",
+		Svid:          "spiffe://aegis.ist/workload/example",
+		Event:         audit.EventExit,
+		Status:        500,
+		Time:          time.Unix(1700000000, 5),
+	})
+	x.Log(log.Info, log.CorrelationId("cid-2"), "secret", "created")
+	x.Log(log.Debug, "no", "correlation id")
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(c.requests))
+	}
+	r := c.requests[0]
+	if r.Method != http.MethodPost {
+		t.Errorf("method: got %s", r.Method)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization: got %q", got)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(c.bodies[0], &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["resourceLogs"]; !ok {
+		t.Errorf("resourceLogs is missing: %s", c.bodies[0])
+	}
+
+	p := c.payloads(t)[0]
+	if len(p.ResourceLogs) != 1 {
+		t.Fatalf("got %d resources, want 1", len(p.ResourceLogs))
+	}
+	rl := p.ResourceLogs[0]
+	if got := rl.Resource.Attributes; len(got) != 1 ||
+		got[0].Key != "service.name" || got[0].Value.StringValue != "aegis-test" {
+		t.Errorf("resource attributes: got %+v", got)
+	}
+	if len(rl.ScopeLogs) != 2 ||
+		rl.ScopeLogs[0].Scope.Name != scopeAudit ||
+		rl.ScopeLogs[1].Scope.Name != scopeLog {
+		t.Fatalf("scopes: got %+v", rl.ScopeLogs)
+	}
+
+	records := rl.ScopeLogs[0].LogRecords
+	if len(records) != 1 {
+		t.Fatalf("got %d audit records, want 1", len(records))
+	}
+	a := records[0]
+	if a.TimeUnixNano != "1700000000000000005" {
+		t.Errorf("timeUnixNano: got %s", a.TimeUnixNano)
+	}
+	if a.SeverityNumber != SeverityWarn || a.SeverityText != "WARN" {
+		t.Errorf("severity: got %d %s", a.SeverityNumber, a.SeverityText)
+	}
+	if a.TraceId != TraceId("cid-1") || len(a.TraceId) != 32 {
+		t.Errorf("traceId: got %q", a.TraceId)
+	}
+	want := map[string]string{
+		"aegis.correlation_id": "cid-1",
+		"aegis.svid":           "spiffe://aegis.ist/workload/example",
+		"aegis.event":          string(audit.EventExit),
+		"http.method":          http.MethodPost,
+		"http.url":             "/secrets",
+		"http.status_code":     "500",
+	}
+	got := attrs(a)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %q, want %q", k, got[k], v)
+		}
+	}
+	var body audit.Record
+	if err := json.Unmarshal([]byte(a.Body.StringValue), &body); err != nil {
+		t.Errorf("the body is not an audit record: %s", err.Error())
+	} else if body.CorrelationId != "cid-1" || body.Status != 500 {
+		t.Errorf("body: got %+v", body)
+	}
+
+	records = rl.ScopeLogs[1].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("got %d log records, want 2", len(records))
+	}
+	l := records[0]
+	if l.Body.StringValue != "secret created" {
+		t.Errorf("body: got %q", l.Body.StringValue)
+	}
+	if l.SeverityNumber != SeverityInfo || l.SeverityText != "INFO" {
+		t.Errorf("severity: got %d %s", l.SeverityNumber, l.SeverityText)
+	}
+	if l.TraceId != TraceId("cid-2") {
+		t.Errorf("traceId: got %q, want %q", l.TraceId, TraceId("cid-2"))
+	}
+	if got := attrs(l)["aegis.correlation_id"]; got != "cid-2" {
+		t.Errorf("aegis.correlation_id: got %q", got)
+	}
+
+	l = records[1]
+	if l.TraceId != "" || len(l.Attributes) != 0 {
+		t.Errorf("got a correlation id for a line without one: %+v", l)
+	}
+	if l.SeverityNumber != SeverityDebug {
+		t.Errorf("severity: got %d", l.SeverityNumber)
+	}
+}
+
+func TestExportBatches(t *testing.T) {
+	c, s := newCollector(t)
+	x := NewExporter(Config{
+		Endpoint: s.URL, BatchSize: 2, FlushInterval: time.Hour,
+	})
+	for i := 0; i < 5; i++ {
+		x.Log(log.Info, "line", i)
+	}
+	if len(c.payloads(t)) != 2 {
+		t.Errorf("got %d requests before Close, want 2", len(c.payloads(t)))
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.logBodies(t); got != "line 0,line 1,line 2,line 3,line 4" {
+		t.Errorf("got %s", got)
+	}
+	if err := x.Write(audit.JournalEntry{}); err == nil {
+		t.Error("Write succeeded after Close")
+	}
+}
+
+func TestFlushKeepsRecordsWhenTheExportFails(t *testing.T) {
+	c, s := newCollector(t)
+	c.failures = 2
+	x := NewExporter(Config{
+		Endpoint: s.URL, BatchSize: 2, FlushInterval: time.Hour,
+	})
+	defer x.Close()
+
+	correlationIds := func() string {
+		var ids []string
+		for _, p := range c.payloads(t) {
+			for _, r := range p.ResourceLogs[0].ScopeLogs[0].LogRecords {
+				ids = append(ids, attrs(r)["aegis.correlation_id"])
+			}
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// The first two batches fail, and are kept.
+	for i := 0; i < 7; i++ {
+		err := x.Write(audit.JournalEntry{CorrelationId: fmt.Sprintf("cid-%d", i)})
+		if err != nil {
+			t.Fatalf("Write: %s", err.Error())
+		}
+	}
+	if got := correlationIds(); got != "cid-0,cid-1,cid-2,cid-3,cid-4,cid-5" {
+		t.Errorf("got %q, want the records of the failed batches first", got)
+	}
+
+	if err := x.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := correlationIds(); got != "cid-0,cid-1,cid-2,cid-3,cid-4,cid-5,cid-6" {
+		t.Errorf("got %q, want every record, in order", got)
+	}
+}
+
+func TestFlushDropsTheOldestRecordsBeyondMaxBuffered(t *testing.T) {
+	c, s := newCollector(t)
+	c.failures = 100
+	x := NewExporter(Config{
+		Endpoint: s.URL, BatchSize: 2, MaxBuffered: 3, FlushInterval: time.Hour,
+	})
+	defer x.Close()
+
+	for i := 0; i < 6; i++ {
+		x.Log(log.Info, "line", i)
+	}
+	if err := x.Flush(); err == nil {
+		t.Fatal("Flush succeeded against a failing collector")
+	}
+
+	c.mux.Lock()
+	c.failures = 0
+	c.mux.Unlock()
+	if err := x.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.logBodies(t); got != "line 3,line 4,line 5" {
+		t.Errorf("got %q, want the newest MaxBuffered records", got)
+	}
+}
+
+func TestExportCollectorError(t *testing.T) {
+	c, s := newCollector(t)
+	c.status = http.StatusServiceUnavailable
+	x := NewExporter(Config{Endpoint: s.URL, FlushInterval: time.Hour})
+	defer x.Close()
+
+	err := x.Export([]LogRecord{{scope: scopeLog}})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got %v, want the status of the collector", err)
+	}
+}
+
+// TestFatalLnFlushes runs log.FatalLn in a child process, since it exits,
+// and checks that the line reached the collector.
+func TestFatalLnFlushes(t *testing.T) {
+	if endpoint := os.Getenv("AEGIS_TEST_OTLP_FATAL"); endpoint != "" {
+		log.SetBackend(NewExporter(Config{
+			Endpoint: endpoint, FlushInterval: time.Hour,
+		}))
+		log.FatalLn(log.CorrelationId("cid-fatal"), "cannot start")
+		return
+	}
+
+	c, s := newCollector(t)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalLnFlushes$")
+	cmd.Env = append(os.Environ(), "AEGIS_TEST_OTLP_FATAL="+s.URL)
+	out, err := cmd.CombinedOutput()
+	if e, ok := err.(*exec.ExitError); !ok || e.Success() {
+		t.Fatalf("got %v, want the child to exit with an error: %s", err, out)
+	}
+
+	payloads := c.payloads(t)
+	if len(payloads) != 1 {
+		t.Fatalf("got %d requests, want 1: %s", len(payloads), out)
+	}
+	r := payloads[0].ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(r) != 1 || r[0].Body.StringValue != "cannot start" ||
+		r[0].SeverityNumber != SeverityError ||
+		r[0].TraceId != TraceId("cid-fatal") {
+		t.Errorf("got %+v", r)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package otlp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// The types in this file are the subset of the OTLP logs data model that the
+// Exporter uses, in the OTLP/JSON encoding.
+// See https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type logsData struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+// LogRecord is a single OTLP log record.
+type LogRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       Severity   `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 AnyValue   `json:"body"`
+	Attributes           []KeyValue `json:"attributes,omitempty"`
+	TraceId              string     `json:"traceId,omitempty"`
+
+	// The instrumentation scope of the record; not serialized as part of
+	// the record.
+	scope string
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// Severity is the OTel severity number of a log record.
+type Severity int
+
+const SeverityTrace Severity = 1
+const SeverityDebug Severity = 5
+const SeverityInfo Severity = 9
+const SeverityWarn Severity = 13
+const SeverityError Severity = 17
+
+func (s Severity) String() string {
+	switch {
+	case s >= SeverityError:
+		return "ERROR"
+	case s >= SeverityWarn:
+		return "WARN"
+	case s >= SeverityInfo:
+		return "INFO"
+	case s >= SeverityDebug:
+		return "DEBUG"
+	default:
+		return "TRACE"
+	}
+}
+
+// TraceId derives an OTel trace id from an Aegis correlation id, so that all
+// the records of a single request share the same trace id. It returns an
+// empty string for an empty correlation id.
+func TraceId(correlationId string) string {
+	if correlationId == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(correlationId))
+	return hex.EncodeToString(sum[:16])
+}
+
+func attr(key, value string) KeyValue {
+	return KeyValue{Key: key, Value: AnyValue{StringValue: value}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,213 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package redact
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Placeholder replaces the value of non-empty secret string fields.
+const Placeholder = "[REDACTED]"
+
+// Redact returns a copy of v, where every struct field that is tagged as
+// `aegis:"secret"` is cleared. Non-empty secret strings are replaced with
+// Placeholder; secret fields of any other type are set to their zero value.
+//
+// Redact descends into pointers, interfaces, structs, slices, arrays, and map
+// values, so secret fields of nested structs are cleared too; unexported
+// fields included, since `fmt` prints those as well. Values that reference
+// themselves, such as cyclic lists, are copied with the same shape. The copy
+// has the same type as v, and v itself is never modified.
+//
+// Both the `audit` and the `log` packages redact everything they emit, so
+// tagging a field is enough to keep it out of the audit journal and the logs:
+//
+//	type SecretUpsertRequest struct {
+//		Value string `json:"value" aegis:"secret"`
+//	}
+func Redact(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if !needsRedaction(rv.Type()) {
+		return v
+	}
+	r := &redactor{copies: map[visit]reflect.Value{}}
+	return r.redact(rv).Interface()
+}
+
+// IsSecret returns true if the struct field is tagged as `aegis:"secret"`.
+func IsSecret(f reflect.StructField) bool {
+	for _, opt := range strings.Split(f.Tag.Get("aegis"), ",") {
+		if strings.TrimSpace(opt) == "secret" {
+			return true
+		}
+	}
+	return false
+}
+
+// needsRedactionCache maps a reflect.Type to the result of needsRedaction.
+var needsRedactionCache sync.Map
+
+// needsRedaction returns false for types that cannot contain a secret field,
+// so that the common case (strings, numbers, errors) skips copying.
+func needsRedaction(t reflect.Type) bool {
+	if cached, ok := needsRedactionCache.Load(t); ok {
+		return cached.(bool)
+	}
+	needs := containsSecret(t, map[reflect.Type]bool{})
+	needsRedactionCache.Store(t, needs)
+	return needs
+}
+
+// containsSecret implements needsRedaction. visiting holds the types that are
+// being inspected, so that recursive types, such as list nodes, terminate: a
+// type that refers back to itself adds no secret fields of its own.
+func containsSecret(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return containsSecret(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if IsSecret(f) || containsSecret(f.Type, visiting) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// visit identifies a pointer, map, or slice that has been copied.
+type visit struct {
+	ptr    unsafe.Pointer
+	length int
+	typ    reflect.Type
+}
+
+type redactor struct {
+	// The copies of the pointers, maps, and slices seen so far; a value that
+	// is reached again, such as through a cycle, gets the same copy.
+	copies map[visit]reflect.Value
+}
+
+func (r *redactor) redact(v reflect.Value) reflect.Value {
+	if !v.IsValid() || !needsRedaction(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(r.redact(v.Elem()))
+		return c
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{v.UnsafePointer(), 0, v.Type()}
+		if c, ok := r.copies[key]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		r.copies[key] = c
+		c.Elem().Set(r.redact(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			field := c.Field(i)
+			if !f.IsExported() {
+				if !IsSecret(f) && !needsRedaction(f.Type) {
+					continue
+				}
+				// Unexported fields cannot be set through reflection; c
+				// is a fresh, addressable copy, so writing to it through
+				// its address leaves v intact.
+				field = reflect.NewAt(
+					f.Type, unsafe.Pointer(field.UnsafeAddr()),
+				).Elem()
+			}
+			if IsSecret(f) {
+				scrub(field)
+				continue
+			}
+			field.Set(r.redact(field))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{v.UnsafePointer(), v.Len(), v.Type()}
+		if c, ok := r.copies[key]; ok {
+			return c
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		r.copies[key] = c
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(r.redact(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(r.redact(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{v.UnsafePointer(), 0, v.Type()}
+		if c, ok := r.copies[key]; ok {
+			return c
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		r.copies[key] = c
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), r.redact(iter.Value()))
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// scrub overwrites the secret field f.
+func scrub(f reflect.Value) {
+	if f.Kind() == reflect.String {
+		if f.Len() > 0 {
+			f.SetString(Placeholder)
+		}
+		return
+	}
+	f.Set(reflect.Zero(f.Type()))
+}
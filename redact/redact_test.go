@@ -0,0 +1,213 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const secret = "hunter2"
+
+type credentials struct {
+	User     string
+	Password string `aegis:"secret"`
+}
+
+type request struct {
+	Name    string
+	Value   string `json:"value" aegis:"secret"`
+	Token   []byte `aegis:"secret"`
+	Creds   credentials
+	CredPtr *credentials
+	List    []credentials
+	ByName  map[string]credentials
+	Any     any
+	Array   [2]credentials
+}
+
+type wrapper struct {
+	req  request
+	ptr  *credentials
+	pass string `aegis:"secret"`
+	name string
+}
+
+type node struct {
+	Name   string
+	Secret string `aegis:"secret"`
+	Next   *node
+}
+
+func newRequest() request {
+	c := credentials{User: "admin", Password: secret}
+	return request{
+		Name:    "example",
+		Value:   secret,
+		Token:   []byte(secret),
+		Creds:   c,
+		CredPtr: &credentials{User: "admin", Password: secret},
+		List:    []credentials{c, c},
+		ByName:  map[string]credentials{"admin": c},
+		Any:     &c,
+		Array:   [2]credentials{c, c},
+	}
+}
+
+// expectNoSecret fails if the secret appears in any printed or JSON form of v.
+func expectNoSecret(t *testing.T, v any) {
+	t.Helper()
+	for _, format := range []string{"%v", "%+v", "%#v"} {
+		if s := fmt.Sprintf(format, v); strings.Contains(s, secret) {
+			t.Errorf("%s output contains the secret: %s", format, s)
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %s", err.Error())
+	}
+	if strings.Contains(string(b), secret) {
+		t.Errorf("JSON output contains the secret: %s", b)
+	}
+}
+
+func TestRedactNested(t *testing.T) {
+	req := newRequest()
+	r := Redact(req).(request)
+
+	expectNoSecret(t, r)
+	if r.Value != Placeholder {
+		t.Errorf("Value: got %q, want %q", r.Value, Placeholder)
+	}
+	if r.Token != nil {
+		t.Errorf("Token: got %q, want nil", r.Token)
+	}
+	if r.Name != "example" || r.Creds.User != "admin" {
+		t.Errorf("non-secret fields changed: %+v", r)
+	}
+	if r.CredPtr.Password != Placeholder || r.List[1].Password != Placeholder ||
+		r.ByName["admin"].Password != Placeholder ||
+		r.Array[0].Password != Placeholder ||
+		r.Any.(*credentials).Password != Placeholder {
+		t.Errorf("nested secret fields were not redacted: %+v", r)
+	}
+}
+
+func TestRedactDoesNotModifyInput(t *testing.T) {
+	req := newRequest()
+	_ = Redact(&req)
+
+	if req.Value != secret || req.CredPtr.Password != secret ||
+		req.List[0].Password != secret ||
+		req.ByName["admin"].Password != secret ||
+		req.Any.(*credentials).Password != secret {
+		t.Errorf("input was modified: %+v", req)
+	}
+}
+
+func TestRedactEmptySecret(t *testing.T) {
+	r := Redact(credentials{User: "admin"}).(credentials)
+	if r.Password != "" {
+		t.Errorf("empty secret: got %q, want \"\"", r.Password)
+	}
+}
+
+func TestRedactUnexportedFields(t *testing.T) {
+	w := wrapper{
+		req:  newRequest(),
+		ptr:  &credentials{Password: secret},
+		pass: secret,
+		name: "example",
+	}
+	r := Redact(w).(wrapper)
+
+	for _, format := range []string{"%v", "%+v"} {
+		if s := fmt.Sprintf(format, r); strings.Contains(s, secret) {
+			t.Errorf("%s output contains the secret: %s", format, s)
+		}
+	}
+	if r.name != "example" {
+		t.Errorf("name: got %q, want %q", r.name, "example")
+	}
+	if w.pass != secret || w.ptr.Password != secret || w.req.Value != secret {
+		t.Errorf("input was modified: %+v", w)
+	}
+}
+
+func TestRedactInterfaces(t *testing.T) {
+	values := []any{
+		newRequest(),
+		&credentials{Password: secret},
+		map[string]any{"creds": credentials{Password: secret}},
+		[]any{1, "two", credentials{Password: secret}},
+	}
+	expectNoSecret(t, Redact(values))
+	for _, v := range values {
+		expectNoSecret(t, Redact(v))
+	}
+}
+
+func list(n int) *node {
+	var head *node
+	for i := n - 1; i >= 0; i-- {
+		head = &node{Name: fmt.Sprint(i), Secret: secret, Next: head}
+	}
+	return head
+}
+
+func TestRedactDeepValues(t *testing.T) {
+	for _, n := range []int{40, 10000} {
+		r := Redact(list(n)).(*node)
+		count := 0
+		for x := r; x != nil; x = x.Next {
+			if x.Secret != Placeholder {
+				t.Fatalf("list of %d: node %s: got %q", n, x.Name, x.Secret)
+			}
+			count++
+		}
+		if count != n {
+			t.Errorf("list of %d: got %d nodes", n, count)
+		}
+	}
+}
+
+func TestRedactCycles(t *testing.T) {
+	head := list(3)
+	head.Next.Next.Next = head
+
+	r := Redact(head).(*node)
+	if r.Next.Next.Next != r {
+		t.Errorf("the cycle was not preserved")
+	}
+	for i, x := 0, r; i < 3; i, x = i+1, x.Next {
+		if x.Secret != Placeholder {
+			t.Errorf("node %s: got %q", x.Name, x.Secret)
+		}
+	}
+	if head.Secret != secret {
+		t.Errorf("input was modified")
+	}
+
+	m := map[string]any{"creds": credentials{Password: secret}}
+	m["self"] = m
+	rm := Redact(m).(map[string]any)
+	if rm["creds"].(credentials).Password != Placeholder {
+		t.Errorf("map: secret was not redacted")
+	}
+}
+
+func TestRedactPassesThroughPlainValues(t *testing.T) {
+	for _, v := range []any{nil, "text", 42, fmt.Errorf("failed")} {
+		if got := Redact(v); got != v {
+			t.Errorf("Redact(%v): got %v", v, got)
+		}
+	}
+}
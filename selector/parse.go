@@ -0,0 +1,260 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package selector
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenEquals
+	tokenDoubleEquals
+	tokenNotEquals
+	tokenBang
+	tokenOpenParen
+	tokenCloseParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' || strings.IndexByte("._-/", c) >= 0
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && (l.input[l.pos] == ' ' || l.input[l.pos] == '\t') {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEnd, pos: start}, nil
+	}
+
+	c := l.input[l.pos]
+	switch {
+	case c == '=':
+		if strings.HasPrefix(l.input[l.pos:], "==") {
+			l.pos += 2
+			return token{tokenDoubleEquals, "==", start}, nil
+		}
+		l.pos++
+		return token{tokenEquals, "=", start}, nil
+	case c == '!':
+		if strings.HasPrefix(l.input[l.pos:], "!=") {
+			l.pos += 2
+			return token{tokenNotEquals, "!=", start}, nil
+		}
+		l.pos++
+		return token{tokenBang, "!", start}, nil
+	case c == '(':
+		l.pos++
+		return token{tokenOpenParen, "(", start}, nil
+	case c == ')':
+		l.pos++
+		return token{tokenCloseParen, ")", start}, nil
+	case c == ',':
+		l.pos++
+		return token{tokenComma, ",", start}, nil
+	case isIdentChar(c):
+		for l.pos < len(l.input) && isIdentChar(l.input[l.pos]) {
+			l.pos++
+		}
+		return token{tokenIdent, l.input[start:l.pos], start}, nil
+	default:
+		return token{}, fmt.Errorf(
+			"selector: unexpected character %q at position %d", c, start,
+		)
+	}
+}
+
+type parser struct {
+	lexer lexer
+	// The current token.
+	tok token
+}
+
+func (p *parser) advance() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf(
+		"selector: %s at position %d", fmt.Sprintf(format, args...), p.tok.pos,
+	)
+}
+
+func (p *parser) parse() (Selector, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	sel := Selector{}
+	if p.tok.kind == tokenEnd {
+		return sel, nil
+	}
+	for {
+		r, err := p.requirement()
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+
+		switch p.tok.kind {
+		case tokenEnd:
+			return sel, nil
+		case tokenComma:
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.errorf("expected \",\" but found %s", p.tok)
+		}
+	}
+}
+
+func (p *parser) requirement() (Requirement, error) {
+	if p.tok.kind == tokenBang {
+		if err := p.advance(); err != nil {
+			return Requirement{}, err
+		}
+		key, err := p.key()
+		if err != nil {
+			return Requirement{}, err
+		}
+		return Requirement{Key: key, Operator: DoesNotExist}, nil
+	}
+
+	key, err := p.key()
+	if err != nil {
+		return Requirement{}, err
+	}
+
+	switch p.tok.kind {
+	case tokenEnd, tokenComma:
+		return Requirement{Key: key, Operator: Exists}, nil
+	case tokenEquals, tokenDoubleEquals, tokenNotEquals:
+		op := Equals
+		if p.tok.kind == tokenNotEquals {
+			op = NotEquals
+		}
+		if err := p.advance(); err != nil {
+			return Requirement{}, err
+		}
+		value := ""
+		if p.tok.kind == tokenIdent {
+			value = p.tok.value
+			if err := p.advance(); err != nil {
+				return Requirement{}, err
+			}
+		}
+		if err := ValidateValue(value); err != nil {
+			return Requirement{}, fmt.Errorf("selector: %s", err.Error())
+		}
+		return Requirement{Key: key, Operator: op, Values: []string{value}}, nil
+	case tokenIdent:
+		var op Operator
+		switch p.tok.value {
+		case "in":
+			op = In
+		case "notin":
+			op = NotIn
+		default:
+			return Requirement{}, p.errorf(
+				"expected an operator but found %s", p.tok,
+			)
+		}
+		if err := p.advance(); err != nil {
+			return Requirement{}, err
+		}
+		values, err := p.set()
+		if err != nil {
+			return Requirement{}, err
+		}
+		return Requirement{Key: key, Operator: op, Values: values}, nil
+	default:
+		return Requirement{}, p.errorf(
+			"expected an operator but found %s", p.tok,
+		)
+	}
+}
+
+func (p *parser) key() (string, error) {
+	if p.tok.kind != tokenIdent {
+		return "", p.errorf("expected a key but found %s", p.tok)
+	}
+	key := p.tok.value
+	if err := ValidateKey(key); err != nil {
+		return "", fmt.Errorf("selector: %s", err.Error())
+	}
+	return key, p.advance()
+}
+
+// set parses a parenthesized, comma-separated list of values.
+func (p *parser) set() ([]string, error) {
+	if p.tok.kind != tokenOpenParen {
+		return nil, p.errorf("expected \"(\" but found %s", p.tok)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for {
+		value := ""
+		if p.tok.kind == tokenIdent {
+			value = p.tok.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if err := ValidateValue(value); err != nil {
+			return nil, fmt.Errorf("selector: %s", err.Error())
+		}
+		values = append(values, value)
+
+		switch p.tok.kind {
+		case tokenComma:
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		case tokenCloseParen:
+			return values, p.advance()
+		default:
+			return nil, p.errorf("expected \",\" or \")\" but found %s", p.tok)
+		}
+	}
+}
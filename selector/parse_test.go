@@ -0,0 +1,138 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package selector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Selector
+	}{
+		{"", Selector{}},
+		{"  ", Selector{}},
+		{"env=prod,team in (a,b)", Selector{
+			{Key: "env", Operator: Equals, Values: []string{"prod"}},
+			{Key: "team", Operator: In, Values: []string{"a", "b"}},
+		}},
+		{"env==prod", Selector{
+			{Key: "env", Operator: Equals, Values: []string{"prod"}},
+		}},
+		{"env!=prod", Selector{
+			{Key: "env", Operator: NotEquals, Values: []string{"prod"}},
+		}},
+		{"team notin (a)", Selector{
+			{Key: "team", Operator: NotIn, Values: []string{"a"}},
+		}},
+		{"env", Selector{{Key: "env", Operator: Exists}}},
+		{"!deprecated", Selector{{Key: "deprecated", Operator: DoesNotExist}}},
+		{"! deprecated,env", Selector{
+			{Key: "deprecated", Operator: DoesNotExist},
+			{Key: "env", Operator: Exists},
+		}},
+		{" \tenv = prod ,\tteam  in ( a , b ) ", Selector{
+			{Key: "env", Operator: Equals, Values: []string{"prod"}},
+			{Key: "team", Operator: In, Values: []string{"a", "b"}},
+		}},
+		{"env=", Selector{
+			{Key: "env", Operator: Equals, Values: []string{""}},
+		}},
+		{"env!=,team", Selector{
+			{Key: "env", Operator: NotEquals, Values: []string{""}},
+			{Key: "team", Operator: Exists},
+		}},
+		{"team in (a,)", Selector{
+			{Key: "team", Operator: In, Values: []string{"a", ""}},
+		}},
+		{"example.com/env=prod", Selector{
+			{Key: "example.com/env", Operator: Equals, Values: []string{"prod"}},
+		}},
+		{"aegis.ist/team-name in (a.b,c_d)", Selector{
+			{Key: "aegis.ist/team-name", Operator: In, Values: []string{"a.b", "c_d"}},
+		}},
+		// "in" is an operator only after a key.
+		{"in in (in)", Selector{
+			{Key: "in", Operator: In, Values: []string{"in"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"env=prod;", `unexpected character ';' at position 8`},
+		{"env=pr od", `expected "," but found "od" at position 7`},
+		{"env prod", `expected an operator but found "prod" at position 4`},
+		{"env in a", `expected "(" but found "a" at position 7`},
+		{"env in (a b)", `expected "," or ")" but found "b" at position 10`},
+		{"env in (a", `expected "," or ")" but found end of input at position 9`},
+		{"=prod", `expected a key but found "=" at position 0`},
+		{"env=prod,,team", `expected a key but found "," at position 9`},
+		{"env=prod,", `expected a key but found end of input at position 9`},
+		{"!", `expected a key but found end of input at position 1`},
+		{"!env=prod", `expected "," but found "=" at position 4`},
+		{"env=(a)", `expected "," but found "(" at position 4`},
+		{"env=a=b", `expected "," but found "=" at position 5`},
+		{"-env=prod", `invalid label key "-env"`},
+		{"Example.com/env=prod", `invalid label key prefix "Example.com"`},
+		{"a/b/c=prod", `invalid label key prefix "a/b"`},
+		{"example.com/=prod", `invalid label key "example.com/"`},
+		{"env=" + strings.Repeat("a", 64), `invalid label value "aaaa`},
+		{"team in (a,-b)", `invalid label value "-b"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.in)
+		if err == nil {
+			t.Errorf("%q: want an error", tt.in)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "selector: ") ||
+			!strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got %q, want it to contain %q", tt.in, err.Error(), tt.want)
+		}
+	}
+}
+
+func TestSelectorStringRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"env=prod,team in (a,b)",
+		"env!=prod,team notin (a),owner,!deprecated",
+		"example.com/env=",
+	} {
+		s, err := Parse(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.String() != in {
+			t.Errorf("got %q, want %q", s.String(), in)
+		}
+		again, err := Parse(s.String())
+		if err != nil || !reflect.DeepEqual(again, s) {
+			t.Errorf("%q does not parse back: %v", s.String(), err)
+		}
+	}
+}
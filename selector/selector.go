@@ -0,0 +1,164 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Operator string
+
+const Equals Operator = "="
+const NotEquals Operator = "!="
+const In Operator = "in"
+const NotIn Operator = "notin"
+const Exists Operator = "exists"
+const DoesNotExist Operator = "!"
+
+// Requirement is a single condition of a Selector.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	// One value for Equals and NotEquals, one or more values for In and
+	// NotIn, and none for Exists and DoesNotExist.
+	Values []string
+}
+
+// Matches returns true if labels satisfy the requirement. As in Kubernetes,
+// NotEquals and NotIn match the labels that do not have the key at all.
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && contains(r.Values, v)
+	case NotEquals, NotIn:
+		return !ok || !contains(r.Values, v)
+	default:
+		return false
+	}
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case In, NotIn:
+		return r.Key + " " + string(r.Operator) +
+			" (" + strings.Join(r.Values, ",") + ")"
+	default:
+		return r.Key + string(r.Operator) + strings.Join(r.Values, "")
+	}
+}
+
+// Selector selects label sets with Kubernetes-style label selectors, such as
+// `env=prod,team in (a,b),!deprecated`. A Selector matches a label set only
+// if every one of its requirements does; an empty Selector matches
+// everything.
+type Selector []Requirement
+
+// Matches returns true if labels satisfy every requirement of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Parse parses a label selector. The supported requirements are:
+//
+//	key=value, key==value    the label has the value
+//	key!=value               the label is missing, or has another value
+//	key in (v1,v2)           the label has one of the values
+//	key notin (v1,v2)        the label is missing, or has none of the values
+//	key                      the label exists
+//	!key                     the label does not exist
+//
+// Requirements are separated by commas.
+func Parse(s string) (Selector, error) {
+	p := &parser{lexer: lexer{input: s}}
+	return p.parse()
+}
+
+// Keys and values follow the Kubernetes syntax: a key is a name with an
+// optional DNS subdomain prefix, and a value is a name that can be empty.
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+var prefixRe = regexp.MustCompile(
+	`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`,
+)
+
+// ValidateKey returns an error if k is not a valid label key.
+func ValidateKey(k string) error {
+	name := k
+	if i := strings.LastIndex(k, "/"); i >= 0 {
+		prefix := k[:i]
+		name = k[i+1:]
+		if len(prefix) > 253 || !prefixRe.MatchString(prefix) {
+			return fmt.Errorf("invalid label key prefix %q", prefix)
+		}
+	}
+	if !nameRe.MatchString(name) {
+		return fmt.Errorf("invalid label key %q", k)
+	}
+	return nil
+}
+
+// ValidateValue returns an error if v is not a valid label value.
+func ValidateValue(v string) error {
+	if v != "" && !nameRe.MatchString(v) {
+		return fmt.Errorf("invalid label value %q", v)
+	}
+	return nil
+}
+
+// ValidateLabels returns an error for the first invalid key or value in
+// labels, in key order.
+func ValidateLabels(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := ValidateKey(k); err != nil {
+			return err
+		}
+		if err := ValidateValue(labels[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package selector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	prod := map[string]string{"env": "prod", "team": "a", "owner": ""}
+
+	tests := []struct {
+		selector string
+		labels   map[string]string
+		want     bool
+	}{
+		{"", nil, true},
+		{"", prod, true},
+		{"env=prod,team in (a,b)", prod, true},
+		{"env=prod,team in (a,b)", map[string]string{"env": "prod", "team": "c"}, false},
+		{"env=prod,team in (a,b)", map[string]string{"env": "prod"}, false},
+		{"env=prod,team in (a,b)", map[string]string{"env": "dev", "team": "b"}, false},
+		{"env==prod", prod, true},
+		{"env=dev", prod, false},
+		{"env=dev", nil, false},
+		{"env!=dev", prod, true},
+		{"env!=prod", prod, false},
+		{"env!=prod", nil, true},
+		{"team notin (b,c)", prod, true},
+		{"team notin (a,c)", prod, false},
+		{"team notin (a,c)", nil, true},
+		{"owner", prod, true},
+		{"owner=", prod, true},
+		{"owner!=", prod, false},
+		{"owner in (,x)", prod, true},
+		{"deprecated", prod, false},
+		{"!deprecated", prod, true},
+		{"!owner", prod, false},
+		{"env=", map[string]string{}, false},
+		{"example.com/env=prod", map[string]string{"example.com/env": "prod"}, true},
+		{"example.com/env=prod", prod, false},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.selector)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.selector, err)
+		}
+		if got := s.Matches(tt.labels); got != tt.want {
+			t.Errorf("%q matches %v: got %t, want %t",
+				tt.selector, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownOperatorMatchesNothing(t *testing.T) {
+	r := Requirement{Key: "env", Operator: "~", Values: []string{"prod"}}
+	if r.Matches(map[string]string{"env": "prod"}) {
+		t.Error("want an unknown operator to match nothing")
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		want   string
+	}{
+		{nil, ""},
+		{map[string]string{"env": "prod", "example.com/team": "", "a": "b.c-d_e"}, ""},
+		{map[string]string{"env": "prod", "b": "-x", "a": "_y"}, `invalid label value "_y"`},
+		{map[string]string{"b/": "x", "env": "prod"}, `invalid label key "b/"`},
+		{map[string]string{strings.Repeat("k", 64): "x"}, "invalid label key"},
+		{map[string]string{strings.Repeat("p", 254) + "/k": "x"}, "invalid label key prefix"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateLabels(tt.labels)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%v: %v", tt.labels, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want %q", tt.labels, err, tt.want)
+		}
+	}
+}
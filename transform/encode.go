@@ -0,0 +1,471 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The flat formats (Dotenv, Properties, and Ini) encode a JSON object; they
+// flatten nested objects by joining their keys, and encode arrays as JSON
+// strings. Toml encodes nested objects as tables, and arrays as arrays.
+
+// encode converts out, which must be a JSON object, to format.
+func encode(format data.SecretFormat, out string) (string, error) {
+	obj, err := decodeObject(out)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case data.Dotenv:
+		return encodeDotenv(obj)
+	case data.Properties:
+		return encodeProperties(obj)
+	case data.Ini:
+		return encodeIni(obj)
+	case data.Toml:
+		return encodeToml(obj)
+	default:
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func decodeObject(out string) (map[string]any, error) {
+	d := json.NewDecoder(strings.NewReader(out))
+	d.UseNumber()
+
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("a JSON object is required: %s", err.Error())
+	}
+	if d.More() {
+		return nil, fmt.Errorf(
+			"a JSON object is required: unexpected data after the object",
+		)
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("a JSON object is required")
+	}
+	return obj, nil
+}
+
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type entry struct {
+	key   string
+	value any
+}
+
+// flatten lists the scalar and array values under obj, in key order, joining
+// the keys of nested objects with sep. It returns an error if two values
+// have the same flattened key, such as "a_b" and "a" > "b" with "_" as sep,
+// since only one of them would survive in the output.
+func flatten(sep string, obj map[string]any) ([]entry, error) {
+	entries := flattenUnder("", sep, obj)
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if seen[e.key] {
+			return nil, fmt.Errorf(
+				"more than one value has the flattened key %q", e.key,
+			)
+		}
+		seen[e.key] = true
+	}
+	return entries, nil
+}
+
+func flattenUnder(prefix, sep string, obj map[string]any) []entry {
+	var entries []entry
+	for _, k := range sortedKeys(obj) {
+		key := k
+		if prefix != "" {
+			key = prefix + sep + k
+		}
+		if child, ok := obj[k].(map[string]any); ok {
+			entries = append(entries, flattenUnder(key, sep, child)...)
+			continue
+		}
+		entries = append(entries, entry{key, obj[k]})
+	}
+	return entries
+}
+
+// scalar converts a flattened value to a string: arrays are JSON-encoded, and
+// null is the empty string.
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+var dotenvKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// encodeDotenv encodes obj as `KEY=value` lines that godotenv and
+// python-dotenv both read back exactly. Nested keys are joined with "_". The
+// two libraries unescape values differently, so every value is written in
+// the first of these forms that suits it:
+//
+//   - Single-quoted, verbatim: values without `'`, `\\`, or `\r`, that do
+//     not end with `\`.
+//   - Double-quoted, with `\`, `"`, newlines, and carriage returns
+//     backslash-escaped: values that do not end with `\` or `"`.
+//   - Unquoted: single-line values without `#`, that neither start with a
+//     quote, nor start or end with white space.
+//
+// The last two forms also require every `$` to be followed by neither a
+// name, nor `{` or `(`, and not to be preceded by `\`, since godotenv
+// expands, or unescapes, those.
+//
+// Values that fit none of the forms, and values with `${`, which
+// python-dotenv expands in every form, are an error.
+func encodeDotenv(obj map[string]any) (string, error) {
+	entries, err := flatten("_", obj)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		if !dotenvKeyRe.MatchString(e.key) {
+			return "", fmt.Errorf("%q is not a valid variable name", e.key)
+		}
+		v, ok := dotenvValue(scalar(e.value))
+		if !ok {
+			return "", fmt.Errorf(
+				"the value of %q cannot be written so that dotenv parsers "+
+					"read it back exactly", e.key,
+			)
+		}
+		b.WriteString(e.key + "=" + v + "\n")
+	}
+	return b.String(), nil
+}
+
+func dotenvValue(v string) (string, bool) {
+	if strings.Contains(v, "${") {
+		return "", false
+	}
+
+	if !strings.ContainsAny(v, "'\r") && !strings.Contains(v, `\\`) &&
+		!strings.HasSuffix(v, `\`) {
+		return "'" + v + "'", true
+	}
+
+	if literalDollars(v) &&
+		!strings.HasSuffix(v, `\`) && !strings.HasSuffix(v, `"`) {
+		return `"` + strings.NewReplacer(
+			`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`,
+		).Replace(v) + `"`, true
+	}
+
+	if v != "" && v == strings.TrimSpace(v) &&
+		literalDollars(v) && !strings.ContainsAny(v, "#\n\r") &&
+		!strings.ContainsAny(v[:1], `'"`) {
+		return v, true
+	}
+	return "", false
+}
+
+// literalDollars returns true if godotenv does not expand, or unescape, any
+// `$` in v outside of single quotes: every `$` is followed by neither a
+// name, nor `{` or `(`, and is not preceded by `\`.
+func literalDollars(v string) bool {
+	for i := strings.IndexByte(v, '$'); i >= 0; i = strings.IndexByte(v, '$') {
+		if i > 0 && v[i-1] == '\\' {
+			return false
+		}
+		if i+1 < len(v) {
+			c := v[i+1]
+			if c == '{' || c == '(' || c == '_' || c >= '0' && c <= '9' ||
+				c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+				return false
+			}
+		}
+		v = v[i+1:]
+	}
+	return true
+}
+
+// encodeProperties encodes obj as a Java properties file. Nested keys are
+// joined with ".". The output is ISO 8859-1 safe: characters outside of
+// printable ASCII are written as `\uXXXX` escapes, as
+// java.util.Properties.store does.
+func encodeProperties(obj map[string]any) (string, error) {
+	entries, err := flatten(".", obj)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(escapeProperty(e.key, true))
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(scalar(e.value), false))
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			// Spaces separate keys from values, and leading spaces of
+			// values are ignored.
+			b.WriteString(`\ `)
+		case strings.ContainsRune("=:#!", r):
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			writeUnicodeEscape(&b, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// writeUnicodeEscape writes r as a `\uXXXX` escape, or as a surrogate pair of
+// escapes if it is outside of the Basic Multilingual Plane.
+func writeUnicodeEscape(b *strings.Builder, r rune) {
+	if r == utf8.RuneError {
+		r = 0xfffd
+	}
+	if r > 0xffff {
+		r -= 0x10000
+		fmt.Fprintf(b, `\u%04x\u%04x`, 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+		return
+	}
+	fmt.Fprintf(b, `\u%04x`, r)
+}
+
+// encodeIni encodes obj as an INI file. Top-level values come first, without
+// a section; each top-level object becomes a section, whose nested keys are
+// joined with ".". Values that would not survive as bare values are
+// double-quoted, with `\`, `"`, newlines, carriage returns, and tabs
+// backslash-escaped.
+func encodeIni(obj map[string]any) (string, error) {
+	var b strings.Builder
+	var sections []string
+	for _, k := range sortedKeys(obj) {
+		if _, ok := obj[k].(map[string]any); ok {
+			sections = append(sections, k)
+			continue
+		}
+		writeIniEntry(&b, k, obj[k])
+	}
+
+	for _, s := range sections {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		entries, err := flatten(".", obj[s].(map[string]any))
+		if err != nil {
+			return "", fmt.Errorf("section %q: %s", s, err.Error())
+		}
+		b.WriteString("[" + quoteIniIfNeeded(s, "[]") + "]\n")
+		for _, e := range entries {
+			writeIniEntry(&b, e.key, e.value)
+		}
+	}
+	return b.String(), nil
+}
+
+func writeIniEntry(b *strings.Builder, key string, value any) {
+	b.WriteString(quoteIniIfNeeded(key, "=[]"))
+	b.WriteString(" = ")
+	b.WriteString(quoteIniIfNeeded(scalar(value), ""))
+	b.WriteByte('\n')
+}
+
+func quoteIniIfNeeded(s, special string) string {
+	if s != "" && s == strings.TrimSpace(s) &&
+		!strings.ContainsAny(s, ";#\"\\\n\r\t"+special) {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\', '"':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// encodeToml encodes obj as a TOML v1.0.0 document: top-level values come
+// first, then a table for every nested object, in key order. Objects inside
+// arrays are written as inline tables. TOML has no null, so null values are
+// an error.
+func encodeToml(obj map[string]any) (string, error) {
+	var b bytes.Buffer
+	if err := writeTomlTable(&b, nil, obj); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeTomlTable(b *bytes.Buffer, path []string, obj map[string]any) error {
+	var tables []string
+	for _, k := range sortedKeys(obj) {
+		if _, ok := obj[k].(map[string]any); ok {
+			tables = append(tables, k)
+			continue
+		}
+		v, err := tomlValue(obj[k], append(path, k))
+		if err != nil {
+			return err
+		}
+		b.WriteString(tomlKey(k) + " = " + v + "\n")
+	}
+
+	for _, k := range tables {
+		child := append(append([]string{}, path...), k)
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		keys := make([]string, len(child))
+		for i, c := range child {
+			keys[i] = tomlKey(c)
+		}
+		b.WriteString("[" + strings.Join(keys, ".") + "]\n")
+		if err := writeTomlTable(b, child, obj[k].(map[string]any)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tomlValue(v any, path []string) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf(
+			"%q is null, which TOML cannot represent", strings.Join(path, "."),
+		)
+	case string:
+		return tomlString(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return scalar(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := tomlValue(item, append(path, fmt.Sprint(i)))
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]any:
+		var items []string
+		for _, k := range sortedKeys(v) {
+			s, err := tomlValue(v[k], append(path, k))
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(k)+" = "+s)
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	default:
+		return "", fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+var tomlBareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if tomlBareKeyRe.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+// tomlString writes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\', '"':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
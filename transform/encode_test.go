@@ -0,0 +1,476 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"github.com/magiconair/properties"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// tricky holds the characters that each format needs to escape.
+var tricky = []string{
+	"plain",
+	"with space",
+	" leading and trailing ",
+	`backslash \ and quote " inside`,
+	`"quoted" start`,
+	"new\nline, tab\t, and return\r",
+	"dollar $HOME and ${HOME}, backtick `ls`",
+	"comment ; and # and !",
+	"separators = and :",
+	"[brackets]",
+	"ünïcödé and 😀",
+	"",
+}
+
+// trickyObject returns a JSON object with every tricky value, flat and
+// nested, and the flattened keys and values that the flat formats should
+// parse back.
+func trickyObject(sep string) (string, map[string]string) {
+	obj := map[string]any{}
+	nested := map[string]any{}
+	want := map[string]string{}
+	for i, v := range tricky {
+		k := "key" + string(rune('a'+i))
+		obj[k] = v
+		nested[k] = v
+		want[k] = v
+		want["nested"+sep+k] = v
+	}
+	obj["nested"] = nested
+	obj["number"] = json.Number("1.5e3")
+	obj["bool"] = true
+	obj["list"] = []any{"x", json.Number("1")}
+	obj["null"] = nil
+	want["number"] = "1.5e3"
+	want["bool"] = "true"
+	want["list"] = `["x",1]`
+	want["null"] = ""
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		panic(err)
+	}
+	return string(b), want
+}
+
+func encodeSecret(t *testing.T, value string, format data.SecretFormat) string {
+	t.Helper()
+	out, err := Transform(secret(value, "", format))
+	if err != nil {
+		t.Fatalf("Transform: %s", err.Error())
+	}
+	return out
+}
+
+// dotenvValues are the values that dotenv parsers read differently, unless
+// they are written with care.
+var dotenvValues = []string{
+	`ends with a backslash \`,
+	`ends with a quote "`,
+	`it's a backslash \`,
+	`it's a "quote"`,
+	`it's $ 5, or 5$`,
+	`escaped \$HOME and \` + "`ls`",
+	`double \\ backslash`,
+	`escapes \n \t \" \'`,
+	`'single' start`,
+	"multi\nline",
+	"it's multi\nline",
+	"return\r",
+	`\`,
+	`"`,
+	`'`,
+}
+
+// pythonDotenv reads `KEY=value` lines as python-dotenv does: single-quoted
+// values unescape `\\` and `\'`; double-quoted values unescape `\\`, `\'`,
+// `\"`, and `\a`, `\b`, `\f`, `\n`, `\r`, `\t`, and `\v`; unquoted values
+// are trimmed, and their ` #` comments removed. Values with `${`, which
+// python-dotenv expands, fail the test.
+func pythonDotenv(t *testing.T, s string) map[string]string {
+	t.Helper()
+	single := regexp.MustCompile(`^'((?:\\'|[^'])*)'`)
+	double := regexp.MustCompile(`^"((?:\\"|[^"])*)"`)
+	singleEscapes := regexp.MustCompile(`\\[\\']`)
+	doubleEscapes := regexp.MustCompile(`\\[\\'"abfnrtv]`)
+	comment := regexp.MustCompile(`\s+#.*`)
+	unescape := strings.NewReplacer(
+		`\a`, "\a", `\b`, "\b", `\f`, "\f", `\n`, "\n", `\r`, "\r",
+		`\t`, "\t", `\v`, "\v",
+	)
+
+	vars := map[string]string{}
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			t.Fatalf("python-dotenv: no value in %q", s)
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var value string
+		switch {
+		case strings.HasPrefix(s, "'"):
+			m := single.FindStringSubmatch(s)
+			if m == nil {
+				t.Fatalf("python-dotenv: unterminated value of %s", key)
+			}
+			value = singleEscapes.ReplaceAllStringFunc(m[1], func(e string) string {
+				return e[1:]
+			})
+			s = s[len(m[0]):]
+		case strings.HasPrefix(s, `"`):
+			m := double.FindStringSubmatch(s)
+			if m == nil {
+				t.Fatalf("python-dotenv: unterminated value of %s", key)
+			}
+			value = doubleEscapes.ReplaceAllStringFunc(m[1], func(e string) string {
+				if e == `\\` || e == `\'` || e == `\"` {
+					return e[1:]
+				}
+				return unescape.Replace(e)
+			})
+			s = s[len(m[0]):]
+		default:
+			end := strings.IndexAny(s, "\r\n")
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(comment.ReplaceAllString(s[:end], ""))
+			s = s[end:]
+		}
+		if strings.Contains(value, "${") {
+			t.Fatalf("python-dotenv would expand the value of %s: %q", key, value)
+		}
+
+		line := strings.IndexByte(s, '\n')
+		if line < 0 {
+			line = len(s) - 1
+		}
+		if rest := strings.TrimSpace(s[:line]); rest != "" {
+			t.Fatalf("python-dotenv: unexpected %q after the value of %s", rest, key)
+		}
+		s = s[line+1:]
+		vars[key] = value
+	}
+	return vars
+}
+
+func TestDotenvRoundTrip(t *testing.T) {
+	obj := map[string]any{}
+	want := map[string]string{}
+	for i, v := range append(append([]string{}, tricky...), dotenvValues...) {
+		if strings.Contains(v, "${") {
+			continue
+		}
+		k := fmt.Sprintf("KEY_%d", i)
+		obj[k] = v
+		want[k] = v
+	}
+	obj["nested"] = map[string]any{"key": `nested \`}
+	want["nested_key"] = `nested \`
+	obj["number"] = json.Number("1.5e3")
+	want["number"] = "1.5e3"
+	obj["list"] = []any{"x", json.Number("1")}
+	want["list"] = `["x",1]`
+	obj["null"] = nil
+	want["null"] = ""
+	value, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := encodeSecret(t, string(value), data.Dotenv)
+
+	got, err := godotenv.Unmarshal(out)
+	if err != nil {
+		t.Fatalf("godotenv: %s\n%s", err.Error(), out)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("godotenv: %s: got %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("godotenv: got %d variables, want %d", len(got), len(want))
+	}
+
+	got = pythonDotenv(t, out)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("python-dotenv: %s: got %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("python-dotenv: got %d variables, want %d", len(got), len(want))
+	}
+
+	// Each value on its own, since the terminator of one value can hide the
+	// misreading of another.
+	for k, v := range want {
+		out := encodeSecret(t, fmt.Sprintf(`{"A":%q}`, v), data.Dotenv)
+		got, err := godotenv.Unmarshal(out)
+		if err != nil || got["A"] != v {
+			t.Errorf("godotenv: %s: got %q, %v, want %q\n%s", k, got["A"], err, v, out)
+		}
+		if got := pythonDotenv(t, out)["A"]; got != v {
+			t.Errorf("python-dotenv: %s: got %q, want %q\n%s", k, got, v, out)
+		}
+	}
+}
+
+func TestDotenvQuoting(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`plain $HOME`, `'plain $HOME'`},
+		{`ends "`, `'ends "'`},
+		{"multi\nline", "'multi\nline'"},
+		{`it's "x" \ y`, `"it's \"x\" \\ y"`},
+		{"it's\r\n", `"it's\r\n"`},
+		{`ends \`, `ends \`},
+		{`it's $ 5 \`, `it's $ 5 \`},
+		{``, `''`},
+	}
+	for _, tt := range tests {
+		v, ok := dotenvValue(tt.value)
+		if !ok || v != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.value, v, ok, tt.want)
+		}
+	}
+}
+
+func TestDotenvUnrepresentableValues(t *testing.T) {
+	for _, v := range []string{
+		"${HOME}",
+		`it's $5 "`,
+		`it's $5`,
+		`it's \$ 5`,
+		`it's # \`,
+		` it's \`,
+		"it's $5\n",
+	} {
+		value, _ := json.Marshal(map[string]string{"A": v})
+		_, err := Transform(secret(string(value), "", data.Dotenv))
+		if err == nil || !strings.Contains(err.Error(), `"A"`) {
+			t.Errorf("%q: got %v, want an error", v, err)
+		}
+	}
+}
+
+func TestPropertiesRoundTrip(t *testing.T) {
+	value, want := trickyObject(".")
+	out := encodeSecret(t, value, data.Properties)
+
+	p, err := properties.Load([]byte(out), properties.ISO_8859_1)
+	if err != nil {
+		t.Fatalf("properties: %s\n%s", err.Error(), out)
+	}
+	// Expansion of ${...} is a feature of the parser, not of the format.
+	p.DisableExpansion = true
+	got := p.Map()
+	// The parser decodes surrogate pairs one half at a time, unlike
+	// java.util.Properties; see TestPropertiesEscaping.
+	for k, v := range want {
+		if strings.Contains(v, "😀") {
+			delete(want, k)
+			delete(got, k)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q\noutput:\n%s", got, want, out)
+	}
+	for _, r := range out {
+		if r > 0x7e {
+			t.Errorf("output is not ASCII: %q", r)
+		}
+	}
+}
+
+func TestPropertiesEscaping(t *testing.T) {
+	out := encodeSecret(t, `{"a b":" x=ü😀\\"}`, data.Properties)
+	want := `a\ b=\ x\=\u00fc\ud83d\ude00\\` + "\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+// parseIni reads the INI dialect that encodeIni writes: `key = value` lines
+// under optional `[section]` headers, where keys, values, and section names
+// are either bare, or double-quoted with backslash escapes. The keys of a
+// section are prefixed with the section name and ".".
+func parseIni(t *testing.T, s string) map[string]string {
+	t.Helper()
+	unquote := func(s string) string {
+		if !strings.HasPrefix(s, `"`) {
+			return s
+		}
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			t.Fatalf("unterminated quoted string: %s", s)
+		}
+		var b strings.Builder
+		escaped := false
+		for _, r := range s[1 : len(s)-1] {
+			if !escaped && r == '\\' {
+				escaped = true
+				continue
+			}
+			if escaped {
+				switch r {
+				case 'n':
+					r = '\n'
+				case 'r':
+					r = '\r'
+				case 't':
+					r = '\t'
+				}
+				escaped = false
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	// splitEntry splits a line at the first " = " outside of quotes.
+	splitEntry := func(line string) (string, string) {
+		quoted, escaped := false, false
+		for i, r := range line {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\' && quoted:
+				escaped = true
+			case r == '"':
+				quoted = !quoted
+			case !quoted && strings.HasPrefix(line[i:], " = "):
+				return line[:i], line[i+3:]
+			}
+		}
+		t.Fatalf("not an entry: %s", line)
+		return "", ""
+	}
+
+	values := map[string]string{}
+	section := ""
+	for _, line := range strings.Split(s, "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = unquote(line[1:len(line)-1]) + "."
+		default:
+			k, v := splitEntry(line)
+			values[section+unquote(k)] = unquote(v)
+		}
+	}
+	return values
+}
+
+func TestIniRoundTrip(t *testing.T) {
+	value, want := trickyObject(".")
+	out := encodeSecret(t, value, data.Ini)
+
+	got := parseIni(t, out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q\noutput:\n%s", got, want, out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.ContainsAny(line, ";#") && !strings.Contains(line, `"`) {
+			t.Errorf("comment characters are not quoted: %s", line)
+		}
+	}
+}
+
+func TestIniEscaping(t *testing.T) {
+	out := encodeSecret(t, `{"a":"x\\y\n\"z\"\t","b c":"d","[e]":{"f":"g"}}`, data.Ini)
+	want := "a = \"x\\\\y\\n\\\"z\\\"\\t\"\nb c = d\n\n[\"[e]\"]\nf = g\n"
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestTomlRoundTrip(t *testing.T) {
+	obj := map[string]any{
+		"nested": map[string]any{"deeper": map[string]any{"x": "y"}},
+		"list":   []any{"x", float64(1), map[string]any{"a": "b"}},
+		"number": float64(1500),
+		"bool":   true,
+		"a.b":    "dotted key",
+	}
+	for i, v := range tricky {
+		obj["key"+string(rune('a'+i))] = v
+		obj["nested"].(map[string]any)["key "+string(rune('a'+i))] = v
+	}
+	value, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := encodeSecret(t, string(value), data.Toml)
+
+	var got map[string]any
+	if _, err := toml.Decode(out, &got); err != nil {
+		t.Fatalf("toml: %s\n%s", err.Error(), out)
+	}
+	// Compare through JSON, since TOML decodes integers as int64.
+	gotJson, _ := json.Marshal(got)
+	wantJson, _ := json.Marshal(obj)
+	if string(gotJson) != string(wantJson) {
+		t.Errorf("got  %s\nwant %s\noutput:\n%s", gotJson, wantJson, out)
+	}
+}
+
+func TestTomlNull(t *testing.T) {
+	_, err := Transform(secret(`{"a":{"b":null}}`, "", data.Toml))
+	if err == nil || !strings.Contains(err.Error(), `"a.b" is null`) {
+		t.Errorf("got %v, want a null error", err)
+	}
+}
+
+func TestFlattenedKeyCollisions(t *testing.T) {
+	tests := []struct {
+		format data.SecretFormat
+		value  string
+	}{
+		{data.Dotenv, `{"a_b":"1","a":{"b":"2"}}`},
+		{data.Properties, `{"a.b":"1","a":{"b":"2"}}`},
+		{data.Ini, `{"s":{"a.b":"1","a":{"b":"2"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			_, err := Transform(secret(tt.value, "", tt.format))
+			if err == nil || !strings.Contains(err.Error(), `"a`) {
+				t.Errorf("got %v, want a collision error", err)
+			}
+		})
+	}
+}
+
+func TestFlatFormatsRequireAnObject(t *testing.T) {
+	for _, f := range []data.SecretFormat{
+		data.Dotenv, data.Properties, data.Ini, data.Toml,
+	} {
+		if _, err := Transform(secret(`[1,2]`, "", f)); err == nil {
+			t.Errorf("%s: got no error for a JSON array", f)
+		}
+	}
+}
+
+func TestDotenvInvalidName(t *testing.T) {
+	_, err := Transform(secret(`{"not valid":"x"}`, "", data.Dotenv))
+	if err == nil {
+		t.Error("got no error for an invalid variable name")
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Funcs returns the functions that secret templates can use, in addition to
+// the text/template builtins. The functions are pure: none of them touch
+// the file system, the network, or the environment.
+//
+// Encoding:
+//
+//	b64enc s           standard base64 encoding of s
+//	b64dec s           decodes standard base64; an error if s is invalid
+//	urlquery s         escapes s for use in a URL query
+//	toJson v           JSON encoding of v
+//	quote s            s as a double-quoted, escaped Go string literal
+//
+// Strings:
+//
+//	upper s, lower s, trim s
+//	trimPrefix prefix s, trimSuffix suffix s
+//	replace old new s  replaces every old in s with new
+//	split sep s        splits s into a list
+//	join sep list      joins the items of list, which may be of any type
+//
+// Defaults:
+//
+//	default d v        v, or d if v is empty (nil, "", 0, false, or an
+//	                   empty list or object)
+//	empty v            true if v is empty
+//	required msg v     v, or an error with msg if v is empty
+//
+// JSON paths:
+//
+//	get path v         the value at the dot-separated path in v, such as
+//	                   "db.hosts.0.name"; an error if there is none
+//	has path v         true if there is a value at the path in v
+//
+// Other secrets:
+//
+//	secretRef name key the value at the key path in the secret named name,
+//	                   or the whole value if key is ""; see Options
+//
+// The value comes last, so that the functions can be used in pipelines:
+//
+//	{{ .password | b64enc }}
+//	{{ get "db.port" . | default 5432 }}
+//
+// Since referring to a missing key is an error, use `has` for optional keys:
+//
+//	{{ if has "db.host" . }}{{ get "db.host" . }}{{ else }}localhost{{ end }}
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"b64enc":     b64enc,
+		"b64dec":     b64dec,
+		"urlquery":   url.QueryEscape,
+		"toJson":     toJson,
+		"quote":      strconv.Quote,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"default":    dflt,
+		"empty":      empty,
+		"required":   required,
+		"get":        get,
+		"has":        has,
+		"secretRef":  noResolver,
+	}
+}
+
+// noResolver stands in for `secretRef` where there is no Resolver.
+func noResolver(name, _ string) (any, error) {
+	return nil, fmt.Errorf("cannot resolve secret %q: no resolver", name)
+}
+
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func toJson(v any) (string, error) {
+	b, err := marshalJson(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func join(sep string, list any) (string, error) {
+	if list == nil {
+		return "", nil
+	}
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("%T is not a list", list)
+	}
+	items := make([]string, v.Len())
+	for i := range items {
+		items[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(items, sep), nil
+}
+
+func empty(v any) bool {
+	if v == nil {
+		return true
+	}
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return err == nil && f == 0
+	}
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return r.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return r.IsNil()
+	default:
+		return r.IsZero()
+	}
+}
+
+func dflt(d, v any) any {
+	if empty(v) {
+		return d
+	}
+	return v
+}
+
+func required(msg string, v any) (any, error) {
+	if empty(v) {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// lookup walks path in v; path segments are object keys or list indexes.
+func lookup(path string, v any) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, p := range strings.Split(path, ".") {
+		switch c := v.(type) {
+		case map[string]any:
+			next, ok := c[p]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func get(path string, v any) (any, error) {
+	found, ok := lookup(path, v)
+	if !ok {
+		return nil, fmt.Errorf("no value at %q", path)
+	}
+	return found, nil
+}
+
+func has(path string, v any) bool {
+	_, ok := lookup(path, v)
+	return ok
+}
@@ -0,0 +1,177 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const funcsInput = `{
+	"user": " Admin ",
+	"password": "p&ss w<rd>",
+	"encoded": "aHVudGVyMg==",
+	"id": 123456789012345678,
+	"zero": 0,
+	"empty": "",
+	"none": null,
+	"tags": ["a", "b", 3],
+	"db": {"hosts": [{"name": "h1"}, {"name": "h2"}], "port": 5432}
+}`
+
+func execute(t *testing.T, text string) (string, error) {
+	t.Helper()
+	return Transform(secret(funcsInput, text, ""))
+}
+
+func TestFuncs(t *testing.T) {
+	tests := []struct {
+		fn, text, want string
+	}{
+		{"b64enc", `{{.password | b64enc}}`, "cCZzcyB3PHJkPg=="},
+		{"b64dec", `{{.encoded | b64dec}}`, "hunter2"},
+		{"b64dec", `{{.password | b64enc | b64dec}}`, "p&ss w<rd>"},
+		{"urlquery", `{{.password | urlquery}}`, "p%26ss+w%3Crd%3E"},
+		{"toJson", `{{.db.hosts | toJson}}`, `[{"name":"h1"},{"name":"h2"}]`},
+		{"toJson", `{{.password | toJson}}`, `"p&ss w<rd>"`},
+		{"toJson", `{{.id | toJson}}`, "123456789012345678"},
+		{"quote", `{{.user | quote}}`, `" Admin "`},
+		{"upper", `{{.user | upper}}`, " ADMIN "},
+		{"lower", `{{.user | lower}}`, " admin "},
+		{"trim", `{{.user | trim}}`, "Admin"},
+		{"trimPrefix", `{{trimPrefix " Ad" .user}}`, "min "},
+		{"trimSuffix", `{{trimSuffix "in " .user}}`, " Adm"},
+		{"replace", `{{replace " " "_" .user}}`, "_Admin_"},
+		{"split", `{{split "," "a,b,c" | toJson}}`, `["a","b","c"]`},
+		{"join", `{{join "," .tags}}`, "a,b,3"},
+		{"join", `{{split "," "a,b" | join "+"}}`, "a+b"},
+		{"join", `{{join "," .none}}`, ""},
+		{"default", `{{.empty | default "x"}}`, "x"},
+		{"default", `{{.none | default "x"}}`, "x"},
+		{"default", `{{.zero | default 7}}`, "7"},
+		{"default", `{{.user | default "x"}}`, " Admin "},
+		{"default", `{{.db.port | default 1}}`, "5432"},
+		{"empty", `{{empty .empty}} {{empty .zero}} {{empty .tags}}`, "true true false"},
+		{"required", `{{.user | required "user is required"}}`, " Admin "},
+		{"get", `{{get "db.hosts.1.name" .}}`, "h2"},
+		{"get", `{{get "db.port" .}}`, "5432"},
+		{"get", `{{get "" .tags | toJson}}`, `["a","b",3]`},
+		{"has", `{{if has "db.nope" .}}{{get "db.nope" .}}{{else}}fallback{{end}}`, "fallback"},
+		{"has", `{{has "db.hosts.0" .}} {{has "db.hosts.2" .}}`, "true false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			got, err := execute(t, tt.text)
+			if err != nil {
+				t.Fatalf("%s: %s", tt.text, err.Error())
+			}
+			if got != tt.want {
+				t.Errorf("%s: got %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuncErrors(t *testing.T) {
+	tests := []struct {
+		fn, text, want string
+	}{
+		{"b64dec", `{{b64dec "not base64!"}}`, "illegal base64 data"},
+		{"join", `{{join "," .user}}`, "string is not a list"},
+		{"join", `{{join "," .db}}`, "is not a list"},
+		{"required", `{{.empty | required "password is required"}}`, "password is required"},
+		{"required", `{{.none | required "none is required"}}`, "none is required"},
+		{"get", `{{get "db.nope" .}}`, `no value at "db.nope"`},
+		{"get", `{{get "db.hosts.2" .}}`, `no value at "db.hosts.2"`},
+		{"get", `{{get "db.hosts.-1" .}}`, `no value at "db.hosts.-1"`},
+		{"get", `{{get "db.hosts.x" .}}`, `no value at "db.hosts.x"`},
+		{"get", `{{get "user.x" .}}`, `no value at "user.x"`},
+		{"secretRef", `{{secretRef "other" "key"}}`, "no resolver"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			_, err := execute(t, tt.text)
+			if err == nil {
+				t.Fatalf("%s: got no error", tt.text)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: error %q does not contain %q",
+					tt.text, err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+// TestFuncsList pins the function map, so that adding a function requires
+// updating this list, and reviewing the function against the sandbox rules.
+func TestFuncsList(t *testing.T) {
+	want := []string{
+		"b64dec", "b64enc", "default", "empty", "get", "has", "join",
+		"lower", "quote", "replace", "required", "secretRef", "split",
+		"toJson", "trim", "trimPrefix", "trimSuffix", "upper", "urlquery",
+	}
+	var got []string
+	for name := range Funcs() {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestFuncsSandbox checks that the code of the functions cannot reach the
+// file system, the network, or the environment: funcs.go must not import
+// any package that can.
+func TestFuncsSandbox(t *testing.T) {
+	forbidden := map[string]bool{
+		"io/fs": true, "io/ioutil": true, "net": true, "os": true,
+		"path/filepath": true, "plugin": true, "runtime": true,
+		"syscall": true, "unsafe": true,
+	}
+	f, err := parser.ParseFile(
+		token.NewFileSet(), "funcs.go", nil, parser.ImportsOnly,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		root := strings.SplitN(path, "/", 2)[0]
+		if forbidden[path] || root == "os" || root == "syscall" ||
+			root == "net" && path != "net/url" {
+			t.Errorf("funcs.go imports %q", path)
+		}
+	}
+}
+
+func TestTemplatesCannotReadTheEnvironment(t *testing.T) {
+	t.Setenv("AEGIS_FUNCS_TEST", "hunter2")
+	for _, text := range []string{
+		`{{env "AEGIS_FUNCS_TEST"}}`,
+		`{{getenv "AEGIS_FUNCS_TEST"}}`,
+		`{{readFile "/etc/hostname"}}`,
+		`{{template "/etc/hostname"}}`,
+	} {
+		got, err := execute(t, text)
+		if err == nil || strings.Contains(got, "hunter2") {
+			t.Errorf("%s: got %q, %v; want an error", text, got, err)
+		}
+	}
+	if os.Getenv("AEGIS_FUNCS_TEST") != "hunter2" {
+		t.Error("the environment was modified")
+	}
+}
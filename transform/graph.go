@@ -0,0 +1,141 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"sort"
+	"sync"
+)
+
+// Graph tracks which secrets reference which, so that the transformed
+// values that depend on a secret can be re-rendered when it changes:
+//
+//	g.Update(s) // after every upsert
+//	for _, name := range g.Dependents(s.Name) {
+//		// Re-render the secret named name.
+//	}
+//
+// A Graph is safe for concurrent use.
+type Graph struct {
+	mux sync.RWMutex
+	// Secret name -> names of the secrets it references.
+	references map[string]map[string]bool
+	// Secret name -> names of the secrets that reference it.
+	dependents map[string]map[string]bool
+}
+
+// NewGraph creates an empty Graph.
+func NewGraph() *Graph {
+	return &Graph{
+		references: map[string]map[string]bool{},
+		dependents: map[string]map[string]bool{},
+	}
+}
+
+// Update records the references of the template of s, replacing the ones
+// that were recorded for it before.
+func (g *Graph) Update(s data.SecretStored) error {
+	names, err := References(s)
+	if err != nil {
+		return err
+	}
+	g.Set(s.Name, names)
+	return nil
+}
+
+// Set records that the secret named name references the secrets named
+// references, replacing the references that were recorded for it before.
+func (g *Graph) Set(name string, references []string) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.remove(name)
+	if len(references) == 0 {
+		return
+	}
+	set := map[string]bool{}
+	for _, r := range references {
+		set[r] = true
+		if g.dependents[r] == nil {
+			g.dependents[r] = map[string]bool{}
+		}
+		g.dependents[r][name] = true
+	}
+	g.references[name] = set
+}
+
+// Remove forgets the references of the secret named name, such as when it
+// is deleted.
+func (g *Graph) Remove(name string) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.remove(name)
+}
+
+func (g *Graph) remove(name string) {
+	for r := range g.references[name] {
+		delete(g.dependents[r], name)
+		if len(g.dependents[r]) == 0 {
+			delete(g.dependents, r)
+		}
+	}
+	delete(g.references, name)
+}
+
+// References returns the names of the secrets that the secret named name
+// references directly, sorted.
+func (g *Graph) References(name string) []string {
+	g.mux.RLock()
+	defer g.mux.RUnlock()
+	return sortedSet(g.references[name])
+}
+
+// Dependents returns the names of the secrets that reference the secret
+// named name, directly or indirectly, in the order to re-render them: every
+// secret comes after the secrets it references. The secret itself is not
+// included. Secrets in a reference cycle are returned once, in name order.
+func (g *Graph) Dependents(name string) []string {
+	g.mux.RLock()
+	defer g.mux.RUnlock()
+
+	visited := map[string]bool{name: true}
+	var order []string
+	// Depth-first search over the dependents; reversing the post-order
+	// yields a topological order.
+	var visit func(n string)
+	visit = func(n string) {
+		for _, d := range sortedSet(g.dependents[n]) {
+			if visited[d] {
+				continue
+			}
+			visited[d] = true
+			visit(d)
+			order = append(order, d)
+		}
+	}
+	visit(name)
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+func sortedSet(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(set))
+	for n := range set {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
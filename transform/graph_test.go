@@ -0,0 +1,100 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphDependents(t *testing.T) {
+	// db <- url <- dsn <- app
+	//    <------------- app
+	// key <- app
+	g := NewGraph()
+	g.Set("url", []string{"db"})
+	g.Set("dsn", []string{"url"})
+	g.Set("app", []string{"dsn", "db", "key"})
+	g.Set("cache", []string{"key"})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"db", "url,dsn,app"},
+		{"url", "dsn,app"},
+		{"key", "cache,app"},
+		{"app", ""},
+		{"unknown", ""},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(g.Dependents(tt.name), ","); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGraphDependentsComeAfterTheirReferences(t *testing.T) {
+	// A diamond, where the name order differs from the dependency order.
+	g := NewGraph()
+	g.Set("a", []string{"z", "y"})
+	g.Set("y", []string{"x"})
+	g.Set("z", []string{"x", "y"})
+
+	got := g.Dependents("x")
+	if strings.Join(got, ",") != "y,z,a" {
+		t.Errorf("got %v, want [y z a]", got)
+	}
+}
+
+func TestGraphDependentsWithACycle(t *testing.T) {
+	g := NewGraph()
+	g.Set("a", []string{"b"})
+	g.Set("b", []string{"a"})
+	g.Set("c", []string{"b"})
+
+	got := g.Dependents("a")
+	if strings.Join(got, ",") != "b,c" {
+		t.Errorf("got %v, want [b c]", got)
+	}
+}
+
+func TestGraphUpdate(t *testing.T) {
+	g := NewGraph()
+	if err := g.Update(named("app", "", `{{secretRef "db" ""}}{{secretRef "key" ""}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.References("app"); strings.Join(got, ",") != "db,key" {
+		t.Errorf("got references %v", got)
+	}
+
+	// Updating replaces the references recorded before.
+	if err := g.Update(named("app", "", `{{secretRef "db" ""}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Dependents("key"); len(got) != 0 {
+		t.Errorf("got dependents of key %v, want none", got)
+	}
+	if got := g.Dependents("db"); strings.Join(got, ",") != "app" {
+		t.Errorf("got dependents of db %v", got)
+	}
+
+	if err := g.Update(named("app", "", `{{`)); err == nil {
+		t.Error("want an invalid template to be an error")
+	}
+	if got := g.References("app"); strings.Join(got, ",") != "db" {
+		t.Errorf("want a failed update to keep the references, got %v", got)
+	}
+
+	g.Remove("app")
+	if got := g.Dependents("db"); len(got) != 0 {
+		t.Errorf("got dependents of db %v after removing app", got)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+)
+
+// Keys renders s one value per key, for the sidecars that write every key
+// of a secret to a file of its own, as Kubernetes secret volumes do.
+//
+// Without a template, the values of a structured secret (see
+// data.SecretStored.Values) are returned as they are. With a template, the
+// template is rendered as Transform renders it, and its output must be a
+// JSON object: its string members are returned as they are, and its other
+// members as JSON. The format of the secret does not apply.
+//
+// If a stage fails, Keys returns an *Error.
+func Keys(s data.SecretStored) (map[string]string, error) {
+	return KeysWith(s, Options{})
+}
+
+// KeysWith is like Keys, but resolves the `secretRef` calls of the template
+// as configured by o.
+func KeysWith(s data.SecretStored, o Options) (map[string]string, error) {
+	if o.WorkloadId == "" {
+		o.WorkloadId = s.Name
+	}
+	if s.Encoding == data.Base64 {
+		return nil, &Error{
+			Secret: s.Name, Stage: StageKeys,
+			Err: fmt.Errorf("binary values have no keys"),
+		}
+	}
+
+	if s.Meta.Template == "" && s.IsStructured() {
+		values := make(map[string]string, len(s.Values))
+		for k, v := range s.Values {
+			values[k] = v
+		}
+		return values, nil
+	}
+
+	out := s.Value
+	if s.Meta.Template != "" {
+		r := &refs{options: o}
+		rendered, err := r.render(s.Name, s.Meta.Template, s.Value)
+		if err != nil {
+			return nil, err
+		}
+		out = rendered
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(out), &members); err != nil {
+		return nil, &Error{
+			Secret: s.Name, Stage: StageKeys,
+			Err: fmt.Errorf("the value is not a JSON object"),
+		}
+	}
+
+	values := make(map[string]string, len(members))
+	for k, m := range members {
+		var str string
+		if err := json.Unmarshal(m, &str); err == nil {
+			values[k] = str
+			continue
+		}
+		var b bytes.Buffer
+		if err := json.Compact(&b, m); err != nil {
+			return nil, &Error{Secret: s.Name, Stage: StageKeys, Err: err}
+		}
+		values[k] = b.String()
+	}
+	return values, nil
+}
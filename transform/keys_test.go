@@ -0,0 +1,112 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"errors"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeys(t *testing.T) {
+	structured := data.SecretStored{Name: "db"}
+	structured.UpdateValues(map[string]string{"user": "admin", "pass": "hunter2"}, 2, time.Now())
+
+	tests := []struct {
+		name string
+		s    data.SecretStored
+		want map[string]string
+	}{
+		{
+			"structured",
+			structured,
+			map[string]string{"user": "admin", "pass": "hunter2"},
+		},
+		{
+			"legacy json object",
+			secret(`{"user":"admin","port":5432,"tags":[ "a" ]}`, "", data.None),
+			map[string]string{"user": "admin", "port": "5432", "tags": `["a"]`},
+		},
+		{
+			"template",
+			secret(`{"user":"admin"}`, `{"USER":"{{.user}}","N":1}`, data.Yaml),
+			map[string]string{"USER": "admin", "N": "1"},
+		},
+		{
+			"template of a structured secret",
+			data.SecretStored{
+				Name: "db", Value: structured.Value, Values: structured.Values,
+				Meta: data.SecretMeta{Template: `{"dsn":"{{.user}}:{{.pass}}"}`},
+			},
+			map[string]string{"dsn": "admin:hunter2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Keys(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeysErrors(t *testing.T) {
+	binary := secret("AAEC", "", data.None)
+	binary.Encoding = data.Base64
+
+	tests := []struct {
+		name  string
+		s     data.SecretStored
+		stage Stage
+	}{
+		{"binary", binary, StageKeys},
+		{"not an object", secret(`["a"]`, "", data.None), StageKeys},
+		{"opaque", secret("hunter2", "", data.None), StageKeys},
+		{"template output is not an object", secret("a", `{{.}}`, data.None), StageKeys},
+		{"invalid template", secret("a", `{{`, data.None), StageTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Keys(tt.s)
+			var te *Error
+			if !errors.As(err, &te) || te.Stage != tt.stage {
+				t.Errorf("want a %s *Error, got %v", tt.stage, err)
+			}
+		})
+	}
+}
+
+func TestKeysWith(t *testing.T) {
+	r := resolver(named("db", `{"user":"admin"}`, ""))
+	s := named("app", "", `{"user":"{{secretRef "db" "user"}}"}`)
+
+	got, err := KeysWith(s, Options{Resolver: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, map[string]string{"user": "admin"}) {
+		t.Errorf("got %v", got)
+	}
+
+	if _, err := Keys(s); err == nil {
+		t.Error("want secretRef without a resolver to be an error")
+	}
+	r.denied = map[string][]string{"app": {"db"}}
+	if _, err := KeysWith(s, Options{Resolver: r}); err == nil {
+		t.Error("want a denied reference to be an error")
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"fmt"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Resolver resolves the `secretRef` calls of secret templates.
+type Resolver interface {
+	// Secret returns the stored secret named name, or an error if there is
+	// none.
+	Secret(name string) (data.SecretStored, error)
+	// CanRead returns true if the workload with workloadId may read the
+	// secret named name.
+	CanRead(workloadId, name string) bool
+}
+
+// Options configures TransformWith.
+type Options struct {
+	// Resolves `secretRef` calls. Without a Resolver, templates that call
+	// `secretRef` fail.
+	Resolver Resolver
+	// The workload that the secret is transformed for. Every secret that
+	// the template references, directly or through the templates of the
+	// referenced secrets, must be readable by this workload. Defaults to
+	// the name of the secret, since secrets are named after the workloads
+	// that own them.
+	WorkloadId string
+}
+
+// refs tracks the `secretRef` calls of a single transformation.
+type refs struct {
+	options Options
+	// Names of the secrets being rendered, outermost first.
+	stack []string
+}
+
+// secretRef implements the `secretRef "name" "key"` template function. It
+// returns the value at the key path (as understood by `get`) in the named
+// secret; an empty key returns the whole value. If the referenced secret
+// has a template, the key is looked up in its rendered value.
+func (r *refs) secretRef(name, key string) (any, error) {
+	for i, n := range r.stack {
+		if n == name {
+			cycle := append(append([]string{}, r.stack[i:]...), name)
+			return nil, fmt.Errorf(
+				"reference cycle: %s", strings.Join(cycle, " -> "),
+			)
+		}
+	}
+
+	if r.options.Resolver == nil {
+		return nil, fmt.Errorf("cannot resolve secret %q: no resolver", name)
+	}
+	if !r.options.Resolver.CanRead(r.options.WorkloadId, name) {
+		return nil, fmt.Errorf(
+			"workload %q may not read secret %q", r.options.WorkloadId, name,
+		)
+	}
+	s, err := r.options.Resolver.Secret(name)
+	if err != nil {
+		return nil, err
+	}
+
+	value := s.Value
+	if s.Meta.Template != "" {
+		value, err = r.render(s.Name, s.Meta.Template, s.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key == "" {
+		return value, nil
+	}
+
+	parsed, err := decodeJson(value)
+	if err != nil {
+		return nil, fmt.Errorf("secret %q is not a JSON value", name)
+	}
+	found, ok := lookup(key, parsed)
+	if !ok {
+		return nil, fmt.Errorf("secret %q has no value at %q", name, key)
+	}
+	return found, nil
+}
+
+// References returns the names of the secrets that the template of s
+// references with `secretRef` calls, sorted and without duplicates. Only
+// calls whose secret name is a string literal can be found.
+func References(s data.SecretStored) ([]string, error) {
+	if s.Meta.Template == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(s.Name).Funcs(Funcs()).Parse(s.Meta.Template)
+	if err != nil {
+		return nil, &Error{Secret: s.Name, Stage: StageTemplate, Err: err}
+	}
+
+	names := map[string]bool{}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectRefs(t.Tree.Root, names)
+		}
+	}
+	return sortedSet(names), nil
+}
+
+func collectRefs(n parse.Node, names map[string]bool) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			collectRefs(c, names)
+		}
+	case *parse.ActionNode:
+		collectRefs(n.Pipe, names)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			collectRefs(c, names)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) > 1 {
+			if id, ok := n.Args[0].(*parse.IdentifierNode); ok &&
+				id.Ident == "secretRef" {
+				if s, ok := n.Args[1].(*parse.StringNode); ok {
+					names[s.Text] = true
+				}
+			}
+		}
+		for _, c := range n.Args {
+			collectRefs(c, names)
+		}
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, names)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, names)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, names)
+	case *parse.TemplateNode:
+		collectRefs(n.Pipe, names)
+	}
+}
+
+func collectBranch(n *parse.BranchNode, names map[string]bool) {
+	collectRefs(n.Pipe, names)
+	collectRefs(n.List, names)
+	collectRefs(n.ElseList, names)
+}
@@ -0,0 +1,221 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"errors"
+	"fmt"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"strings"
+	"testing"
+)
+
+// fakeResolver resolves the secrets in secrets. Every workload may read
+// every secret, except the ones in denied, which maps workload ids to the
+// names of the secrets they may not read.
+type fakeResolver struct {
+	secrets map[string]data.SecretStored
+	denied  map[string][]string
+}
+
+func (r fakeResolver) Secret(name string) (data.SecretStored, error) {
+	s, ok := r.secrets[name]
+	if !ok {
+		return data.SecretStored{}, fmt.Errorf("no secret %q", name)
+	}
+	return s, nil
+}
+
+func (r fakeResolver) CanRead(workloadId, name string) bool {
+	for _, n := range r.denied[workloadId] {
+		if n == name {
+			return false
+		}
+	}
+	return true
+}
+
+func named(name, value, template string) data.SecretStored {
+	return data.SecretStored{
+		Name:  name,
+		Value: value,
+		Meta:  data.SecretMeta{Template: template},
+	}
+}
+
+func resolver(secrets ...data.SecretStored) fakeResolver {
+	r := fakeResolver{secrets: map[string]data.SecretStored{}}
+	for _, s := range secrets {
+		r.secrets[s.Name] = s
+	}
+	return r
+}
+
+func TestSecretRef(t *testing.T) {
+	db := named("db", `{"user":"admin","hosts":[{"name":"h1"},{"name":"h2"}]}`, "")
+	url := named("url", `{"host":"h1"}`, `{"url":"postgres://{{.host}}"}`)
+	r := resolver(db, url,
+		named("password", "hunter2", ""),
+		named("dsn", "", `{{secretRef "url" "url"}}/app`),
+	)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"whole value", `{{secretRef "password" ""}}`, "hunter2"},
+		{"key", `{{secretRef "db" "user"}}`, "admin"},
+		{"key path", `{{secretRef "db" "hosts.1.name"}}`, "h2"},
+		{"rendered value", `{{secretRef "url" "url"}}`, "postgres://h1"},
+		{"transitive", `{{secretRef "dsn" ""}}`, "postgres://h1/app"},
+		{"in a pipeline", `{{secretRef "password" "" | upper}}`, "HUNTER2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TransformWith(
+				named("app", "", tt.template), Options{Resolver: r},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretRefErrors(t *testing.T) {
+	r := resolver(
+		named("password", "hunter2", ""),
+		named("db", `{"user":"admin"}`, ""),
+		named("a", "", `{{secretRef "b" ""}}`),
+		named("b", "", `{{secretRef "a" ""}}`),
+		named("self", "", `{{secretRef "self" ""}}`),
+		named("indirect", "", `{{secretRef "password" ""}}`),
+	)
+	r.denied = map[string][]string{"app": {"password"}, "other": {"db"}}
+
+	tests := []struct {
+		name     string
+		s        data.SecretStored
+		options  Options
+		contains string
+	}{
+		{
+			"no resolver",
+			named("app", "", `{{secretRef "db" ""}}`), Options{},
+			`cannot resolve secret "db": no resolver`,
+		},
+		{
+			"cycle",
+			r.secrets["a"], Options{Resolver: r},
+			"reference cycle: a -> b -> a",
+		},
+		{
+			"self reference",
+			r.secrets["self"], Options{Resolver: r},
+			"reference cycle: self -> self",
+		},
+		{
+			"denied",
+			named("app", "", `{{secretRef "password" ""}}`), Options{Resolver: r},
+			`workload "app" may not read secret "password"`,
+		},
+		{
+			"denied through another secret",
+			named("app", "", `{{secretRef "indirect" ""}}`), Options{Resolver: r},
+			`workload "app" may not read secret "password"`,
+		},
+		{
+			"denied to the given workload",
+			named("app", "", `{{secretRef "db" "user"}}`),
+			Options{Resolver: r, WorkloadId: "other"},
+			`workload "other" may not read secret "db"`,
+		},
+		{
+			"missing secret",
+			named("app", "", `{{secretRef "missing" ""}}`), Options{Resolver: r},
+			`no secret "missing"`,
+		},
+		{
+			"key of a value that is not JSON",
+			named("other", "", `{{secretRef "password" "user"}}`),
+			Options{Resolver: r},
+			`secret "password" is not a JSON value`,
+		},
+		{
+			"missing key",
+			named("app", "", `{{secretRef "db" "pass"}}`), Options{Resolver: r},
+			`secret "db" has no value at "pass"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TransformWith(tt.s, tt.options)
+			var te *Error
+			if !errors.As(err, &te) || te.Stage != StageRender {
+				t.Fatalf("want a render *Error, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("got %q, want it to contain %q", err.Error(), tt.contains)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{"no template", "", nil},
+		{"no references", `{{.user | upper}}`, nil},
+		{"action", `{{secretRef "a" ""}}`, []string{"a"}},
+		{"duplicates", `{{secretRef "b" "x"}}{{secretRef "a" ""}}{{secretRef "b" "y"}}`,
+			[]string{"a", "b"}},
+		{"pipeline", `{{secretRef "a" "" | upper | quote}}`, []string{"a"}},
+		{"argument", `{{printf "%s:%s" (secretRef "a" "") (secretRef "b" "")}}`,
+			[]string{"a", "b"}},
+		{"if", `{{if secretRef "a" ""}}{{secretRef "b" ""}}{{else}}{{secretRef "c" ""}}{{end}}`,
+			[]string{"a", "b", "c"}},
+		{"range", `{{range secretRef "a" "list"}}{{secretRef "b" ""}}{{else}}{{secretRef "c" ""}}{{end}}`,
+			[]string{"a", "b", "c"}},
+		{"with", `{{with secretRef "a" ""}}{{secretRef "b" ""}}{{end}}`,
+			[]string{"a", "b"}},
+		{"define", `{{define "t"}}{{secretRef "a" ""}}{{end}}{{template "t" (secretRef "b" "")}}`,
+			[]string{"a", "b"}},
+		{"variable", `{{$x := secretRef "a" ""}}{{$x}}`, []string{"a"}},
+		{"name is not a literal", `{{secretRef .name ""}}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := References(named("app", "", tt.template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferencesInvalidTemplate(t *testing.T) {
+	_, err := References(named("app", "", `{{secretRef "a" ""`))
+	var te *Error
+	if !errors.As(err, &te) || te.Stage != StageTemplate || te.Secret != "app" {
+		t.Errorf("want a template *Error, got %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Stage is the step of the transformation that failed.
+type Stage string
+
+var StageTemplate Stage = "template"
+var StageRender Stage = "render"
+var StageFormat Stage = "format"
+var StageKeys Stage = "keys"
+
+// Error is the error that Transform returns. It tells which secret, and
+// which stage of the transformation, failed.
+type Error struct {
+	Secret string
+	Stage  Stage
+	Format data.SecretFormat
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Stage == StageFormat {
+		return fmt.Sprintf(
+			"transform: secret %q: cannot format output as %s: %s",
+			e.Secret, e.Format, e.Err.Error(),
+		)
+	}
+	return fmt.Sprintf(
+		"transform: secret %q: %s failed: %s", e.Secret, e.Stage, e.Err.Error(),
+	)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Transform computes the transformed value of s; the value that workloads
+// see:
+//
+//  1. If s.Meta.Template is not empty, it is executed as a Go template. If
+//     s.Value is valid JSON, the template is executed against the parsed
+//     value, so that `{{.username}}` refers to the `username` key of a JSON
+//     object; otherwise, the template is executed against the raw string.
+//     Referring to a missing key is an error. Templates can use the
+//     functions of Funcs.
+//  2. The output is validated and normalized for s.Meta.Format: Json output
+//     is compacted, with object keys sorted; Yaml output is re-encoded as
+//     block-style YAML (JSON output is accepted too, since JSON is YAML);
+//     Dotenv, Toml, Properties, and Ini output is encoded from a JSON
+//     object (see encode.go); None output is left as is.
+//
+// If a stage fails, Transform returns an *Error.
+//
+// Binary values (see data.Base64) are passed through as is; they cannot have
+// a template, or a format other than None.
+//
+// Templates that reference other secrets with `secretRef` need a Resolver;
+// use TransformWith to transform them.
+func Transform(s data.SecretStored) (string, error) {
+	return TransformWith(s, Options{})
+}
+
+// TransformWith is like Transform, but resolves the `secretRef` calls of the
+// template as configured by o.
+func TransformWith(s data.SecretStored, o Options) (string, error) {
+	if o.WorkloadId == "" {
+		o.WorkloadId = s.Name
+	}
+	if s.Encoding == data.Base64 {
+		return binary(s)
+	}
+	out := s.Value
+
+	if s.Meta.Template != "" {
+		r := &refs{options: o}
+		rendered, err := r.render(s.Name, s.Meta.Template, s.Value)
+		if err != nil {
+			return "", err
+		}
+		out = rendered
+	}
+
+	switch s.Meta.Format {
+	case data.Json:
+		return normalizeJson(s.Name, out)
+	case data.Yaml:
+		return normalizeYaml(s.Name, out)
+	case data.Dotenv, data.Properties, data.Ini, data.Toml:
+		v, err := encode(s.Meta.Format, out)
+		if err != nil {
+			return "", &Error{
+				Secret: s.Name, Stage: StageFormat, Format: s.Meta.Format, Err: err,
+			}
+		}
+		return v, nil
+	case data.None, "":
+		return out, nil
+	default:
+		return "", &Error{
+			Secret: s.Name, Stage: StageFormat, Format: s.Meta.Format,
+			Err: fmt.Errorf("unknown format %q", s.Meta.Format),
+		}
+	}
+}
+
+// Apply sets s.ValueTransformed to the result of Transform.
+func Apply(s *data.SecretStored) error {
+	return ApplyWith(s, Options{})
+}
+
+// ApplyWith sets s.ValueTransformed to the result of TransformWith.
+func ApplyWith(s *data.SecretStored, o Options) error {
+	v, err := TransformWith(*s, o)
+	if err != nil {
+		return err
+	}
+	s.ValueTransformed = v
+	return nil
+}
+
+func binary(s data.SecretStored) (string, error) {
+	if s.Meta.Template != "" {
+		return "", &Error{
+			Secret: s.Name, Stage: StageTemplate,
+			Err: fmt.Errorf("binary values cannot have a template"),
+		}
+	}
+	if s.Meta.Format != data.None && s.Meta.Format != "" {
+		return "", &Error{
+			Secret: s.Name, Stage: StageFormat, Format: s.Meta.Format,
+			Err: fmt.Errorf("the value is binary"),
+		}
+	}
+	return s.Value, nil
+}
+
+func (r *refs) render(name, text, value string) (string, error) {
+	r.stack = append(r.stack, name)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	funcs := Funcs()
+	funcs["secretRef"] = r.secretRef
+	tmpl, err := template.New(name).
+		Option("missingkey=error").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", &Error{Secret: name, Stage: StageTemplate, Err: err}
+	}
+
+	var input any = value
+	if parsed, err := decodeJson(value); err == nil {
+		input = parsed
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, input); err != nil {
+		return "", &Error{Secret: name, Stage: StageRender, Err: err}
+	}
+	return b.String(), nil
+}
+
+// decodeJson decodes a single JSON value. Numbers are decoded as
+// json.Number, so that large integers, such as account ids, keep all their
+// digits.
+func decodeJson(s string) (any, error) {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the top-level value")
+	}
+	return v, nil
+}
+
+// marshalJson is json.Marshal, without escaping `<`, `>`, and `&`, which
+// are likely to be part of a secret, such as a password.
+func marshalJson(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+func normalizeJson(name, out string) (string, error) {
+	v, err := decodeJson(out)
+	if err != nil {
+		if se, ok := err.(*json.SyntaxError); ok {
+			line, col := position(out, se.Offset)
+			err = fmt.Errorf("line %d, column %d: %s", line, col, se.Error())
+		}
+		return "", &Error{
+			Secret: name, Stage: StageFormat, Format: data.Json, Err: err,
+		}
+	}
+
+	b, err := marshalJson(v)
+	if err != nil {
+		return "", &Error{
+			Secret: name, Stage: StageFormat, Format: data.Json, Err: err,
+		}
+	}
+	return string(b), nil
+}
+
+// normalizeYaml re-encodes every document of out; multi-document output
+// keeps its documents, separated by `---`.
+func normalizeYaml(name, out string) (string, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+
+	d := yaml.NewDecoder(strings.NewReader(out))
+	documents := 0
+	for {
+		var node yaml.Node
+		err := d.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", &Error{
+				Secret: name, Stage: StageFormat, Format: data.Yaml, Err: err,
+			}
+		}
+		blockStyle(&node)
+		documents++
+		if err := enc.Encode(&node); err != nil {
+			return "", &Error{
+				Secret: name, Stage: StageFormat, Format: data.Yaml, Err: err,
+			}
+		}
+	}
+	if documents == 0 {
+		return "", nil
+	}
+	if err := enc.Close(); err != nil {
+		return "", &Error{
+			Secret: name, Stage: StageFormat, Format: data.Yaml, Err: err,
+		}
+	}
+	return b.String(), nil
+}
+
+// blockStyle clears the flow style of the mappings and sequences under n, and
+// the quoting of the scalars, so that JSON input is re-encoded as
+// block-style YAML. The encoder still quotes the strings that would
+// otherwise read as another type, such as "true" or "1".
+func blockStyle(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// position converts a byte offset in s to a 1-based line and column.
+func position(s string, offset int64) (int, int) {
+	line, col := 1, 1
+	for i := 0; i < len(s) && int64(i) < offset-1; i++ {
+		if s[i] == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+	return line, col
+}
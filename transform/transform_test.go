@@ -0,0 +1,152 @@
+/*
+ * .-'_.---._'-.
+ * ||####|(__)||   Protect your secrets, protect your business.
+ *   \\()|##//       Secure your sensitive data with Aegis.
+ *    \\ |#//                    <aegis.ist>
+ *     .\_/.
+ */
+
+package transform
+
+import (
+	"errors"
+	data "github.com/zerotohero-dev/aegis-core/entity/data/v1"
+	"strings"
+	"testing"
+)
+
+func secret(value, template string, format data.SecretFormat) data.SecretStored {
+	return data.SecretStored{
+		Name:  "example",
+		Value: value,
+		Meta:  data.SecretMeta{Template: template, Format: format},
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		s    data.SecretStored
+		want string
+	}{
+		{
+			"template and json",
+			secret(
+				`{"username":"admin","password":"AegisRocks"}`,
+				`{"USER":"{{.username}}", "PASS":"{{.password}}"}`, data.Json,
+			),
+			`{"PASS":"AegisRocks","USER":"admin"}`,
+		},
+		{
+			"large integers keep their digits",
+			secret(`{"id":123456789012345678}`, `{{.id}}`, data.None),
+			"123456789012345678",
+		},
+		{
+			"large integers keep their digits in json",
+			secret(`{"id":123456789012345678,"f":1.5e3}`, "", data.Json),
+			`{"f":1.5e3,"id":123456789012345678}`,
+		},
+		{
+			"json does not escape html characters",
+			secret(`{"password":"a<b>&c"}`, "", data.Json),
+			`{"password":"a<b>&c"}`,
+		},
+		{
+			"json to yaml",
+			secret(`{"b":[1,2],"a":"x"}`, "", data.Yaml),
+			"b:\n  - 1\n  - 2\na: x\n",
+		},
+		{
+			"yaml keeps every document",
+			secret("a: 1\n---\nb: 2\n", "", data.Yaml),
+			"a: 1\n---\nb: 2\n",
+		},
+		{
+			"empty yaml",
+			secret("", "", data.Yaml),
+			"",
+		},
+		{
+			"raw string value",
+			secret("not json", "[{{.}}]", data.None),
+			"[not json]",
+		},
+		{
+			"no format",
+			secret("anything", "", ""),
+			"anything",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transform(tt.s)
+			if err != nil {
+				t.Fatalf("Transform: %s", err.Error())
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     data.SecretStored
+		stage Stage
+		text  string
+	}{
+		{"bad template", secret("{}", "{{.x", ""), StageTemplate, "unclosed"},
+		{"missing key", secret("{}", "{{.x}}", ""), StageRender, `"x"`},
+		{
+			"invalid json",
+			secret("{\n\"a\": x}", "", data.Json), StageFormat,
+			"line 2, column 6",
+		},
+		{"trailing json", secret("{} {}", "", data.Json), StageFormat, "after"},
+		{"invalid yaml", secret("a: [", "", data.Yaml), StageFormat, "yaml"},
+		{"unknown format", secret("{}", "", "xml"), StageFormat, "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Transform(tt.s)
+			var terr *Error
+			if !errors.As(err, &terr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if terr.Stage != tt.stage {
+				t.Errorf("stage: got %q, want %q", terr.Stage, tt.stage)
+			}
+			if !strings.Contains(err.Error(), tt.text) {
+				t.Errorf("error %q does not contain %q", err.Error(), tt.text)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := secret(`{"a":1}`, "", data.Json)
+	if err := Apply(&s); err != nil {
+		t.Fatalf("Apply: %s", err.Error())
+	}
+	if s.ValueTransformed != `{"a":1}` {
+		t.Errorf("got %q", s.ValueTransformed)
+	}
+}
+
+func TestYamlKeepsTypes(t *testing.T) {
+	got, err := Transform(
+		secret(`{"s":"true","n":"1","m":"a\nb","x":null}`, "", data.Yaml),
+	)
+	if err != nil {
+		t.Fatalf("Transform: %s", err.Error())
+	}
+	want := "s: \"true\"\nn: \"1\"\nm: |-\n  a\n  b\nx: null\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}